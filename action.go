@@ -1,8 +1,12 @@
 package tdb
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"path/filepath"
-	"time"
+	"sort"
+	"strings"
 
 	"go.uber.org/zap"
 )
@@ -10,26 +14,46 @@ import (
 const (
 	actionIndex = "__action__"
 
-	actionExp = 15 // action will expire after 15 seconds
+	actionExp = 15 // action will expire after 15 seconds by default
 )
 
+// ExpiryRule sets the action expiry for targets starting with Prefix.
+// The rule with the longest matching prefix wins.
+type ExpiryRule struct {
+	Prefix string
+	Expiry uint32 // idle gap in seconds
+}
+
 // AddAction used to add actions to database
 func (db *TDB) AddAction(target string, ts uint32) error {
+	if err := db.check(true); err != nil {
+		return err
+	}
+
 	db.action.contentLock.Lock()
 
 	err := handleAction(db, target, ts)
+	if err == nil {
+		err = db.actionLog.append(journalSet, target, db.action.content[target])
+	}
+	db.action.contentLock.Unlock()
 	if err != nil {
-		db.action.contentLock.Unlock()
 		return err
 	}
-	db.action.contentLock.Unlock()
 
-	return db.action.writeToDisk()
+	return db.compactActionsIfFull()
 }
 
 // GetActions to get all actions.
 // return targets, starts, lasts, error
 func (db *TDB) GetActions() ([]string, []uint32, []uint32, error) {
+	if err := db.check(false); err != nil {
+		return nil, nil, nil, err
+	}
+	return db.getActions()
+}
+
+func (db *TDB) getActions() ([]string, []uint32, []uint32, error) {
 	keys, values := db.action.getAllInfo()
 
 	var targets []string
@@ -49,39 +73,78 @@ func (db *TDB) GetActions() ([]string, []uint32, []uint32, error) {
 
 // CheckExpirations to check expired actions
 func (db *TDB) CheckExpirations() error {
-	targets, starts, lasts, err := db.GetActions()
-	if err != nil {
+	if err := db.check(true); err != nil {
 		return err
 	}
+	return db.checkExpirations()
+}
 
-	changed := false
-	now := uint32(time.Now().Unix())
+func (db *TDB) checkExpirations() error {
+	now := uint32(db.clock.Now().Unix())
 
 	db.action.contentLock.Lock()
-	for i := 0; i < len(lasts); i++ {
-		if now-lasts[i] > actionExp {
-			changed = true
-			p("expired action", zap.String("target", targets[i]))
-			if err := db.AddSlot(targets[i], starts[i], lasts[i]-starts[i]); err != nil {
+	for target, v := range db.action.content {
+		start, last, err := decodeAction(v)
+		if err != nil {
+			db.action.contentLock.Unlock()
+			return err
+		}
+
+		if now > last && now-last > db.expiryFor(target) {
+			db.log("expired action", zap.String("target", target))
+			if err := db.addSlot(target, start, last-start); err != nil {
+				db.action.contentLock.Unlock()
+				return err
+			}
+			if err := db.deleteActions([]string{target}); err != nil {
 				db.action.contentLock.Unlock()
 				return err
 			}
-			delete(db.action.content, targets[i])
 		}
 	}
 	db.action.contentLock.Unlock()
 
-	if changed {
-		return db.action.writeToDisk()
+	return db.compactActionsIfFull()
+}
+
+// delete actions of the targets, the caller should hold the content lock.
+func (db *TDB) deleteActions(targets []string) error {
+	for _, target := range targets {
+		if _, ok := db.action.content[target]; !ok {
+			continue
+		}
+		delete(db.action.content, target)
+		if err := db.actionLog.append(journalDelete, target, nil); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// compact the action journal into the snapshot if it reached the limit.
+func (db *TDB) compactActionsIfFull() error {
+	if !db.actionLog.full() {
+		return nil
+	}
+	return db.compactActions()
+}
+
+// write the action snapshot and reset the journal.
+func (db *TDB) compactActions() error {
+	db.action.contentLock.RLock()
+	defer db.action.contentLock.RUnlock()
+
+	if err := db.action.writeLocked(); err != nil {
+		return err
+	}
+	return db.actionLog.reset()
+}
+
 func handleAction(db *TDB, target string, ts uint32) error {
 	actions := db.action.content
 	v, ok := actions[target]
 	if !ok {
-		p("new action")
+		db.log("new action")
 		actions[target] = encodeAction(ts, ts+1)
 		return nil
 	}
@@ -91,9 +154,9 @@ func handleAction(db *TDB, target string, ts uint32) error {
 	}
 
 	if ts > last {
-		if ts-last > actionExp {
-			p("new slot", zap.Uint32("ts", ts), zap.Uint32("last", last))
-			if err := db.AddSlot(target, start, last-start); err != nil {
+		if ts-last > db.expiryFor(target) {
+			db.log("new slot", zap.Uint32("ts", ts), zap.Uint32("last", last))
+			if err := db.addSlot(target, start, last-start); err != nil {
 				return err
 			}
 			actions[target] = encodeAction(ts, ts+1)
@@ -104,9 +167,94 @@ func handleAction(db *TDB, target string, ts uint32) error {
 	return nil
 }
 
-// load slot index from file
+// the idle gap in seconds after which an action of the target expires
+func (db *TDB) expiryFor(target string) uint32 {
+	for _, rule := range db.expiryRules {
+		if strings.HasPrefix(target, rule.Prefix) {
+			return rule.Expiry
+		}
+	}
+	return db.expiry
+}
+
+// load the action expiry settings from metadata.
+// Non-zero options override and replace the persisted ones.
+func (db *TDB) loadExpiry() error {
+	db.expiry = actionExp
+	persisted := db.meta.getInfoValue(actionExpKey)
+	if len(persisted) == 4 {
+		db.expiry = binary.LittleEndian.Uint32(persisted)
+	}
+
+	var rules []ExpiryRule
+	var staleKeys []string
+	metaKeys, metaValues := db.meta.getAllInfo()
+	for i, k := range metaKeys {
+		if !strings.HasPrefix(k, actionExpRulePrefix) {
+			continue
+		}
+		if db.opts.ExpiryRules != nil {
+			staleKeys = append(staleKeys, k)
+			continue
+		}
+		if len(metaValues[i]) != 4 {
+			return fmt.Errorf("wrong action expiry rule %q in metadata, byte length should be 4, but is %d", k, len(metaValues[i]))
+		}
+		rules = append(rules, ExpiryRule{
+			Prefix: strings.TrimPrefix(k, actionExpRulePrefix),
+			Expiry: binary.LittleEndian.Uint32(metaValues[i]),
+		})
+	}
+
+	var keys []string
+	var values [][]byte
+	if (db.opts.ActionExpiry != 0 && db.opts.ActionExpiry != db.expiry) || len(persisted) != 4 {
+		if db.opts.ActionExpiry != 0 {
+			db.expiry = db.opts.ActionExpiry
+		}
+		keys = append(keys, actionExpKey)
+		values = append(values, encodeExpiry(db.expiry))
+	}
+	if db.opts.ExpiryRules != nil {
+		rules = db.opts.ExpiryRules
+		for _, rule := range rules {
+			if rule.Expiry == 0 {
+				return errors.New("action expiry of a rule should be greater than 0")
+			}
+			keys = append(keys, actionExpRulePrefix+rule.Prefix)
+			values = append(values, encodeExpiry(rule.Expiry))
+		}
+	}
+
+	// longest prefix first
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].Prefix) > len(rules[j].Prefix)
+	})
+	db.expiryRules = rules
+
+	if db.opts.ReadOnly {
+		return nil
+	}
+	if err := db.meta.deleteInfo(staleKeys); err != nil {
+		return err
+	}
+	return db.meta.updateInfo(keys, values)
+}
+
+func encodeExpiry(exp uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, exp)
+	return b
+}
+
+// load action index from file
 func (db *TDB) loadActionIndex() error {
 	var err error
 	db.action, err = createInfo(filepath.Join(db.path, actionIndex))
+	if err != nil {
+		return err
+	}
+
+	db.actionLog, err = openJournal(db.action, db.opts.ActionLogLimit, db.opts.Sync, db.opts.ReadOnly)
 	return err
 }
@@ -15,7 +15,8 @@ func TestActions(t *testing.T) {
 	lg.InitLogger(true)
 
 	folder := "test_actions"
-	db, err := Open(folder)
+	clock := newFakeClock(time.Date(2017, 4, 2, 10, 0, 0, 0, time.UTC))
+	db, err := OpenWithOptions(folder, Options{Clock: clock})
 	if !assert.NoError(t, err, "should have no error") {
 		t.Fatal(err)
 	}
@@ -25,7 +26,7 @@ func TestActions(t *testing.T) {
 	testAddAction(t, db)
 
 	// test load actions
-	db2, err := Open(folder)
+	db2, err := OpenWithOptions(folder, Options{Clock: clock})
 	if !assert.NoError(t, err, "should have no error") {
 		t.Fatal(err)
 	}
@@ -39,12 +40,12 @@ func TestActions(t *testing.T) {
 	// after test, should have no item
 	testCheckEXP(t, db)
 
-	testReloadEmptyInfo(t, folder)
+	testReloadEmptyInfo(t, folder, clock)
 }
 
 func testFastInsert(t *testing.T, db *TDB) {
 	target := string(randBytes(6))
-	now := uint32(time.Now().Unix())
+	now := uint32(db.clock.Now().Unix())
 
 	p("test fast insert", zap.String("target", target))
 
@@ -62,12 +63,14 @@ func testFastInsert(t *testing.T, db *TDB) {
 	assert.Len(t, starts, 0, "starts count wrong")
 	assert.Len(t, howlongs, 0, "howlong count wrong")
 
-	delete(db.action.content, target)
+	db.action.contentLock.Lock()
+	assert.NoError(t, db.deleteActions([]string{target}), "delete action wrong")
+	db.action.contentLock.Unlock()
 }
 
 func testAddAction(t *testing.T, db *TDB) {
 	target := string(randBytes(6))
-	now := uint32(time.Now().Unix())
+	now := uint32(db.clock.Now().Unix())
 
 	// add new action
 	db.AddAction(target, now)
@@ -98,12 +101,12 @@ func testCheckEXP(t *testing.T, db *TDB) {
 	for k := range db.action.content {
 		keys = append(keys, k)
 	}
-	for i := 0; i < len(keys); i++ {
-		delete(db.action.content, keys[i])
-	}
+	db.action.contentLock.Lock()
+	assert.NoError(t, db.deleteActions(keys), "delete actions wrong")
+	db.action.contentLock.Unlock()
 
 	target := string(randBytes(6))
-	now := uint32(time.Now().Unix())
+	now := uint32(db.clock.Now().Unix())
 
 	// add a should be expired action
 	err := db.AddAction(target, now-actionExp-2)
@@ -119,15 +122,66 @@ func testCheckEXP(t *testing.T, db *TDB) {
 	assert.Len(t, allLasts, 0, "lasts count wrong")
 }
 
-func testReloadEmptyInfo(t *testing.T, folder string) {
-	db, err := Open(folder)
+func testReloadEmptyInfo(t *testing.T, folder string, clock Clock) {
+	db, err := OpenWithOptions(folder, Options{Clock: clock})
 	if !assert.NoError(t, err, "should have no error") {
 		t.Fatal(err)
 	}
 
 	target := string(randBytes(6))
-	now := uint32(time.Now().Unix())
+	now := uint32(db.clock.Now().Unix())
 
 	err = db.AddAction(target, now)
 	assert.NoError(t, err, "error add an action")
 }
+
+func TestExpiryRules(t *testing.T) {
+	folder := "test_expiry_rules"
+	defer os.RemoveAll(folder)
+
+	db, err := OpenWithOptions(folder, Options{
+		Clock:        newFakeClock(time.Date(2017, 4, 2, 10, 0, 0, 0, time.UTC)),
+		ActionExpiry: 60,
+		ExpiryRules: []ExpiryRule{
+			{Prefix: "/home", Expiry: 30},
+			{Prefix: "/home/me/proj", Expiry: 120},
+		},
+	})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint32(60), db.expiryFor("http://example.com"), "default expiry wrong")
+	assert.Equal(t, uint32(30), db.expiryFor("/home/me/a.go"), "rule expiry wrong")
+	assert.Equal(t, uint32(120), db.expiryFor("/home/me/proj/x.go"), "longest prefix should win")
+
+	// heartbeats within the window extend the action
+	now := uint32(db.clock.Now().Unix())
+	target := "/home/me/proj/x.go"
+	assert.NoError(t, db.AddAction(target, now-100), "error add an action")
+	assert.NoError(t, db.AddAction(target, now), "error add an action")
+	starts, _, err := db.GetSlots(target, 0, 0)
+	assert.NoError(t, err, "get slots wrong")
+	assert.Len(t, starts, 0, "should have no slot")
+	assert.NoError(t, db.Close(), "error closing")
+
+	// settings stay the same across reopens
+	db, err = Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint32(60), db.expiryFor("http://example.com"), "default expiry not persisted")
+	assert.Equal(t, uint32(120), db.expiryFor("/home/me/proj/x.go"), "rule not persisted")
+	assert.NoError(t, db.Close(), "error closing")
+
+	// rules get replaced
+	db, err = OpenWithOptions(folder, Options{ExpiryRules: []ExpiryRule{{Prefix: "http", Expiry: 5}}})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	assert.Equal(t, uint32(5), db.expiryFor("http://example.com"), "rule expiry wrong")
+	assert.Equal(t, uint32(60), db.expiryFor("/home/me/proj/x.go"), "old rule should be removed")
+
+	_, err = OpenWithOptions(folder, Options{ExpiryRules: []ExpiryRule{{Prefix: "http"}}})
+	assert.Error(t, err, "zero expiry rule should fail")
+}
@@ -0,0 +1,244 @@
+package tdb
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// without complete rollups, the slots spanning the start of a range are looked for this long before it
+const slotLookback = 24 * 3600
+
+// Bucket is the length of the periods Aggregate sums slots into.
+type Bucket uint8
+
+// Buckets of Aggregate, starting at the boundaries of the database zone offset.
+const (
+	BucketHour Bucket = iota
+	BucketDay
+	BucketWeek // starts on Monday
+	BucketMonth
+)
+
+var bucketNames = []string{"hour", "day", "week", "month"}
+
+func (b Bucket) String() string {
+	if int(b) < len(bucketNames) {
+		return bucketNames[b]
+	}
+	return fmt.Sprintf("bucket(%d)", uint8(b))
+}
+
+// ParseBucket parses the name of a bucket: hour, day, week or month.
+func ParseBucket(name string) (Bucket, error) {
+	for i, n := range bucketNames {
+		if strings.EqualFold(n, name) {
+			return Bucket(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown bucket %q", name)
+}
+
+// BucketTotal is the active seconds of a target in the bucket starting at "Start".
+type BucketTotal struct {
+	Start   uint32
+	Seconds uint32
+}
+
+// Aggregate sums the seconds of the slots of the targets between "start" and "end" into buckets,
+// all targets if "targets" is empty, 0 "end" means no end. A slot spanning buckets is split
+// by the seconds in each of them, only its seconds in the range are counted, even if it starts before "start".
+// The totals of a target are ordered by bucket, empty buckets are left out.
+//
+// When the rollups are complete, the whole days or months in the range are summed from them
+// and only the partial days at its edges are read from the slot files. Otherwise the slot files
+// are read from a day before "start", the seconds of slots starting earlier are not counted then.
+func (db *TDB) Aggregate(targets []string, start, end uint32, bucket Bucket) (map[string][]BucketTotal, error) {
+	if err := db.check(false); err != nil {
+		return nil, err
+	}
+	if int(bucket) >= len(bucketNames) {
+		return nil, fmt.Errorf("unknown bucket %d", bucket)
+	}
+	if end != 0 && end < start {
+		return nil, ErrRange
+	}
+
+	if len(targets) == 0 {
+		targets = db.GetTargets()
+	}
+	limit := uint64(math.MaxUint32) + 1
+	if end != 0 {
+		limit = uint64(end) + 1
+	}
+
+	result := make(map[string][]BucketTotal)
+	for _, target := range targets {
+		aliasedHome, unlock, err := db.lockAlias(target, false)
+		if err != nil {
+			return nil, err
+		}
+		totals := make(map[uint32]uint32)
+		if aliasedHome != "" {
+			err = db.aggregateTarget(totals, aliasedHome, target, uint64(start), limit, bucket)
+		}
+		unlock()
+		if err != nil {
+			return nil, err
+		}
+		result[target] = sortTotals(totals)
+	}
+	return result, nil
+}
+
+// sum the seconds of a target in [from, to) into the buckets, the caller locks the alias
+func (db *TDB) aggregateTarget(totals map[uint32]uint32, aliasedHome, target string, from, to uint64, bucket Bucket) error {
+	// days follow the zone offset of the database, not the zone of the files
+	if bucket != BucketHour && db.RollupsComplete() {
+		period, first, last := rollupRange(from, to, bucket, db.fixedLoc)
+		if first < last {
+			rollups, err := db.readRollups(db.rollupsHome(aliasedHome), uint32(first), uint32(last-1), period)
+			if err != nil {
+				return err
+			}
+			for _, r := range rollups {
+				s, _ := bucketOf(int64(r.Start), bucket, db.fixedLoc)
+				totals[uint32(s)] += uint32(r.Seconds)
+			}
+			if err := db.aggregateSlots(totals, aliasedHome, target, from, first, bucket); err != nil {
+				return err
+			}
+			return db.aggregateSlots(totals, aliasedHome, target, last, to, bucket)
+		}
+	}
+	return db.aggregateSlots(totals, aliasedHome, target, from, to, bucket)
+}
+
+// sum the seconds of a target in [from, to) into the buckets from the slot files,
+// the caller locks the alias
+func (db *TDB) aggregateSlots(totals map[uint32]uint32, aliasedHome, target string, from, to uint64, bucket Bucket) error {
+	if from >= to {
+		return nil
+	}
+	readFrom, err := db.overlapStart(aliasedHome, from)
+	if err != nil {
+		return err
+	}
+	starts, slots, err := db.getSlots(aliasedHome, target, readFrom, uint32(to-1))
+	if err != nil {
+		return err
+	}
+	for i := range starts {
+		for j := range starts[i] {
+			s := uint64(starts[i][j])
+			e := s + uint64(slots[i][j])
+			if s < from {
+				s = from
+			}
+			if e > to {
+				e = to
+			}
+			addToBuckets(totals, s, e, bucket, db.fixedLoc)
+		}
+	}
+	return nil
+}
+
+// get the earliest start of the slots which may span "unix", from the daily rollups.
+// A day ending with activity may have a slot going on into the next one.
+// If the rollups are incomplete, the slots are looked for only a day back.
+func (db *TDB) overlapStart(aliasedHome string, unix uint64) (uint32, error) {
+	if !db.RollupsComplete() {
+		if unix < slotLookback {
+			return 0, nil
+		}
+		return uint32(unix - slotLookback), nil
+	}
+	from, _ := bucketOf(int64(unix), BucketDay, db.fixedLoc)
+	if from > unix {
+		// the day starts before 1970
+		return 0, nil
+	}
+	for from > 0 {
+		prev, _ := bucketOf(int64(from)-1, BucketDay, db.fixedLoc)
+		if prev > from {
+			return 0, nil
+		}
+		rollups, err := db.readRollups(db.rollupsHome(aliasedHome), uint32(prev), uint32(prev), RollupDay)
+		if err != nil {
+			return 0, err
+		}
+		if len(rollups) == 0 || uint64(rollups[0].Last) < from {
+			break
+		}
+		from = prev
+	}
+	return uint32(from), nil
+}
+
+func sortTotals(totals map[uint32]uint32) []BucketTotal {
+	var list []BucketTotal
+	for s, seconds := range totals {
+		list = append(list, BucketTotal{s, seconds})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Start < list[j].Start })
+	return list
+}
+
+// get the rollup period to sum the buckets from and the whole periods [first, last) within [from, to),
+// months for month buckets, days otherwise
+func rollupRange(from, to uint64, bucket Bucket, loc *time.Location) (RollupPeriod, uint64, uint64) {
+	period, b := RollupDay, BucketDay
+	if bucket == BucketMonth {
+		period, b = RollupMonth, BucketMonth
+	}
+	first, next := bucketOf(int64(from), b, loc)
+	if first != from {
+		first = next
+	}
+	last, _ := bucketOf(int64(to), b, loc)
+	if last > to {
+		// the period starts before 1970
+		last = first
+	}
+	return period, first, last
+}
+
+// add the seconds between "from" and "to" to the buckets they are in
+func addToBuckets(totals map[uint32]uint32, from, to uint64, bucket Bucket, loc *time.Location) {
+	for from < to {
+		s, next := bucketOf(int64(from), bucket, loc)
+		if next > to {
+			next = to
+		}
+		totals[uint32(s)] += uint32(next - from)
+		from = next
+	}
+}
+
+// get the start of the bucket containing "unix" and the start of the next one
+func bucketOf(unix int64, bucket Bucket, loc *time.Location) (uint64, uint64) {
+	t := time.Unix(unix, 0).In(loc)
+	year, month, day := t.Date()
+
+	var start, next time.Time
+	switch bucket {
+	case BucketHour:
+		start = time.Date(year, month, day, t.Hour(), 0, 0, 0, loc)
+		next = start.Add(time.Hour)
+	case BucketDay:
+		start = time.Date(year, month, day, 0, 0, 0, 0, loc)
+		next = start.AddDate(0, 0, 1)
+	case BucketWeek:
+		// days since Monday
+		since := (int(t.Weekday()) + 6) % 7
+		start = time.Date(year, month, day-since, 0, 0, 0, 0, loc)
+		next = start.AddDate(0, 0, 7)
+	default:
+		start = time.Date(year, month, 1, 0, 0, 0, 0, loc)
+		next = start.AddDate(0, 1, 0)
+	}
+	return uint64(start.Unix()), uint64(next.Unix())
+}
@@ -0,0 +1,166 @@
+package tdb
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBucket(t *testing.T) {
+	for _, b := range []Bucket{BucketHour, BucketDay, BucketWeek, BucketMonth} {
+		parsed, err := ParseBucket(b.String())
+		assert.NoError(t, err, "parse wrong")
+		assert.Equal(t, b, parsed, "bucket wrong")
+	}
+
+	_, err := ParseBucket("fortnight")
+	assert.Error(t, err, "unknown bucket")
+}
+
+func TestBucketOf(t *testing.T) {
+	loc := time.FixedZone("", 8*3600)
+	// 2017-04-05 Wednesday 10:30:00 in the zone
+	unix := time.Date(2017, 4, 5, 10, 30, 0, 0, loc).Unix()
+
+	tests := []struct {
+		bucket      Bucket
+		start, next time.Time
+	}{
+		{BucketHour, time.Date(2017, 4, 5, 10, 0, 0, 0, loc), time.Date(2017, 4, 5, 11, 0, 0, 0, loc)},
+		{BucketDay, time.Date(2017, 4, 5, 0, 0, 0, 0, loc), time.Date(2017, 4, 6, 0, 0, 0, 0, loc)},
+		{BucketWeek, time.Date(2017, 4, 3, 0, 0, 0, 0, loc), time.Date(2017, 4, 10, 0, 0, 0, 0, loc)},
+		{BucketMonth, time.Date(2017, 4, 1, 0, 0, 0, 0, loc), time.Date(2017, 5, 1, 0, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		start, next := bucketOf(unix, tt.bucket, loc)
+		assert.Equal(t, uint64(tt.start.Unix()), start, "start of %s wrong", tt.bucket)
+		assert.Equal(t, uint64(tt.next.Unix()), next, "next of %s wrong", tt.bucket)
+	}
+
+	// Sunday is the end of a week
+	start, _ := bucketOf(time.Date(2017, 4, 9, 23, 0, 0, 0, loc).Unix(), BucketWeek, loc)
+	assert.Equal(t, uint64(time.Date(2017, 4, 3, 0, 0, 0, 0, loc).Unix()), start, "week wrong")
+}
+
+func TestAggregate(t *testing.T) {
+	folder := "test_aggregate"
+	defer os.RemoveAll(folder)
+
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	offset, err := db.ZoneOffset()
+	assert.NoError(t, err, "zone offset wrong")
+	loc := time.FixedZone("", int(offset))
+	unix := func(day, hour, min int) uint32 {
+		return uint32(time.Date(2017, 4, day, hour, min, 0, 0, loc).Unix())
+	}
+
+	// 23:30 for an hour spans two days
+	assert.NoError(t, db.AddSlot("a", unix(2, 23, 30), 3600), "add slot wrong")
+	assert.NoError(t, db.AddSlot("a", unix(3, 10, 0), 600), "add slot wrong")
+	assert.NoError(t, db.AddSlot("b", unix(3, 10, 0), 60), "add slot wrong")
+
+	result, err := db.Aggregate([]string{"a"}, 0, 0, BucketDay)
+	assert.NoError(t, err, "aggregate wrong")
+	assert.Equal(t, map[string][]BucketTotal{"a": {{unix(2, 0, 0), 1800}, {unix(3, 0, 0), 2400}}}, result, "days wrong")
+
+	result, err = db.Aggregate(nil, 0, 0, BucketHour)
+	assert.NoError(t, err, "aggregate wrong")
+	assert.Equal(t, []BucketTotal{{unix(2, 23, 0), 1800}, {unix(3, 0, 0), 1800}, {unix(3, 10, 0), 600}}, result["a"], "hours wrong")
+	assert.Equal(t, []BucketTotal{{unix(3, 10, 0), 60}}, result["b"], "hours wrong")
+
+	// the seconds after end are not counted
+	result, err = db.Aggregate([]string{"a"}, unix(2, 0, 0), unix(3, 0, 0)-1, BucketWeek)
+	assert.NoError(t, err, "aggregate wrong")
+	// 2017-04-02 is a Sunday
+	monday := uint32(time.Date(2017, 3, 27, 0, 0, 0, 0, loc).Unix())
+	assert.Equal(t, []BucketTotal{{monday, 1800}}, result["a"], "end wrong")
+
+	result, err = db.Aggregate(nil, 0, 0, BucketMonth)
+	assert.NoError(t, err, "aggregate wrong")
+	assert.Equal(t, []BucketTotal{{unix(1, 0, 0), 4200}}, result["a"], "month wrong")
+
+	_, err = db.Aggregate(nil, 10, 5, BucketDay)
+	assert.Equal(t, ErrRange, err, "range wrong")
+	_, err = db.Aggregate(nil, 0, 0, Bucket(9))
+	assert.Error(t, err, "unknown bucket")
+}
+
+func TestAggregateRollupsAndSlots(t *testing.T) {
+	folder := "test_aggregate_paths"
+	defer os.RemoveAll(folder)
+
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	offset, err := db.ZoneOffset()
+	assert.NoError(t, err, "zone offset wrong")
+	loc := time.FixedZone("", int(offset))
+	unix := func(day, hour, min int) uint32 {
+		return uint32(time.Date(2017, 4, day, hour, min, 0, 0, loc).Unix())
+	}
+
+	// 30 hours from 20:00 spans three days
+	assert.NoError(t, db.AddSlot("a", unix(1, 20, 0), 30*3600), "add slot wrong")
+	assert.NoError(t, db.AddSlot("a", unix(2, 23, 30), 3600), "add slot wrong")
+	assert.NoError(t, db.AddSlot("a", unix(3, 10, 0), 600), "add slot wrong")
+	assert.NoError(t, db.AddSlot("a", unix(4, 8, 0), 100), "add slot wrong")
+	assert.NoError(t, db.AddSlot("b", unix(3, 10, 0), 60), "add slot wrong")
+
+	ranges := [][2]uint32{
+		{0, 0},
+		{unix(2, 0, 0), unix(4, 0, 0) - 1},
+		{unix(2, 12, 0) + 7, unix(4, 8, 0) + 49},
+		{unix(1, 21, 0), unix(2, 1, 0)},
+	}
+	buckets := []Bucket{BucketHour, BucketDay, BucketWeek, BucketMonth}
+
+	var fromRollups []map[string][]BucketTotal
+	for _, r := range ranges {
+		for _, b := range buckets {
+			result, err := db.Aggregate(nil, r[0], r[1], b)
+			assert.NoError(t, err, "aggregate wrong")
+			fromRollups = append(fromRollups, result)
+		}
+	}
+
+	// only the seconds in the range count, also of the slot starting before it
+	result, err := db.Aggregate([]string{"a"}, unix(2, 12, 0)+7, unix(4, 8, 0)+49, BucketDay)
+	assert.NoError(t, err, "aggregate wrong")
+	expected := []BucketTotal{{unix(2, 0, 0), 12*3600 - 7 + 1800}, {unix(3, 0, 0), 7200 + 1800 + 600}, {unix(4, 0, 0), 50}}
+	assert.Equal(t, expected, result["a"], "days wrong")
+
+	// the same totals from the slot files only
+	assert.NoError(t, db.meta.deleteInfo([]string{rollupsKey}), "delete flag wrong")
+	assert.False(t, db.RollupsComplete(), "rollups should be incomplete")
+	i := 0
+	for _, r := range ranges {
+		for _, b := range buckets {
+			result, err := db.Aggregate(nil, r[0], r[1], b)
+			assert.NoError(t, err, "aggregate wrong")
+			assert.Equal(t, fromRollups[i], result, "totals of %s in %v differ", b, r)
+			i++
+		}
+	}
+
+	// without rollups, the slot files are read only a day before the range
+	assert.NoError(t, db.AddSlot("c", unix(10, 0, 0), 3*86400), "add slot wrong")
+	result, err = db.Aggregate([]string{"c"}, unix(12, 12, 0), 0, BucketDay)
+	assert.NoError(t, err, "aggregate wrong")
+	assert.Empty(t, result["c"], "slot starting long before should be left out")
+
+	assert.NoError(t, db.RebuildRollups(context.Background()), "rebuild wrong")
+	result, err = db.Aggregate([]string{"c"}, unix(12, 12, 0), 0, BucketDay)
+	assert.NoError(t, err, "aggregate wrong")
+	assert.Equal(t, []BucketTotal{{unix(12, 0, 0), 12 * 3600}}, result["c"], "days wrong")
+}
@@ -0,0 +1,25 @@
+package tdb
+
+import "time"
+
+// Clock provides the current time and timers, it can be replaced in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// wallClock is the default Clock using the system time.
+type wallClock struct{}
+
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+func (wallClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Now returns the current time of the database clock, see Options.Clock.
+func (db *TDB) Now() time.Time {
+	return db.clock.Now()
+}
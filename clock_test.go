@@ -0,0 +1,87 @@
+package tdb
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock fires timers only when the test ticks.
+type fakeClock struct {
+	now     time.Time
+	timers  chan chan time.Time
+	pending chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, timers: make(chan chan time.Time, 1)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.timers <- ch
+	return ch
+}
+
+// tick fires the pending timer, it returns once the worker waits for the next one.
+func (c *fakeClock) tick() {
+	if c.pending == nil {
+		c.pending = <-c.timers
+	}
+	c.pending <- c.now
+	c.pending = <-c.timers
+}
+
+func TestFakeClock(t *testing.T) {
+	folder := "test_fake_clock"
+	defer os.RemoveAll(folder)
+
+	created := time.Date(2017, 4, 2, 10, 0, 0, 0, time.FixedZone("test", 8*3600))
+	clock := newFakeClock(created)
+	db, err := OpenWithOptions(folder, Options{Clock: clock})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	assert.Equal(t, created, db.Now(), "now wrong")
+
+	// metadata uses the clock
+	createAt, err := db.CreateAt()
+	assert.NoError(t, err, "should have no error to get db creation")
+	assert.Equal(t, uint32(created.Unix()), createAt, "creation is wrong")
+	offset, err := db.ZoneOffset()
+	assert.NoError(t, err, "should have no error to get zone offset")
+	assert.Equal(t, int32(8*3600), offset, "offset value is wrong")
+
+	// action is kept until the clock passes the expiry
+	target := string(randBytes(6))
+	start := uint32(created.Unix())
+	assert.NoError(t, db.AddAction(target, start), "error add an action")
+	assert.NoError(t, db.AddAction(target, start+10), "error add an action")
+
+	clock.now = created.Add((10 + actionExp) * time.Second)
+	assert.NoError(t, db.CheckExpirations(), "error check expiration")
+	targets, _, _, err := db.GetActions()
+	assert.NoError(t, err, "get all actions wrong")
+	assert.Len(t, targets, 1, "action should not expire yet")
+
+	clock.now = clock.now.Add(time.Second)
+	assert.NoError(t, db.CheckExpirations(), "error check expiration")
+	targets, _, _, err = db.GetActions()
+	assert.NoError(t, err, "get all actions wrong")
+	assert.Len(t, targets, 0, "action should expire")
+
+	starts, slots, err := db.GetSlots(target, 0, 0)
+	assert.NoError(t, err, "get slots wrong")
+	if assert.Len(t, starts, 1, "should have one file") {
+		assert.Equal(t, []uint32{start}, starts[0], "start wrong")
+		assert.Equal(t, []uint32{10}, slots[0], "slot wrong")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/tracerun/tdb"
+)
+
+// action is an open action printed by the actions command.
+type action struct {
+	Target string `json:"target"`
+	Start  uint32 `json:"start"`
+	Last   uint32 `json:"last"`
+}
+
+func getActions(db *tdb.TDB) ([]action, error) {
+	targets, starts, lasts, err := db.GetActions()
+	if err != nil {
+		return nil, err
+	}
+	actions := make([]action, len(targets))
+	for i := range targets {
+		actions[i] = action{targets[i], starts[i], lasts[i]}
+	}
+	return actions, nil
+}
+
+func runActions(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("actions", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 {
+		return errUsage
+	}
+
+	db, err := tdb.OpenWithOptions(positional[0], tdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	actions, err := getActions(db)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return writeJSON(out, actions)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tSTART\tLAST")
+	for _, a := range actions {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", a.Target, formatUnix(a.Start), formatUnix(a.Last))
+	}
+	return w.Flush()
+}
+
+func runExpire(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("expire", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 {
+		return errUsage
+	}
+
+	db, err := tdb.OpenWithOptions(positional[0], tdb.Options{MustExist: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	before, err := getActions(db)
+	if err != nil {
+		return err
+	}
+	if err := db.CheckExpirations(); err != nil {
+		return err
+	}
+	after, err := getActions(db)
+	if err != nil {
+		return err
+	}
+
+	expired := len(before) - len(after)
+	if *asJSON {
+		return writeJSON(out, map[string]int{"expired": expired, "open": len(after)})
+	}
+	fmt.Fprintf(out, "%d actions expired, %d still open\n", expired, len(after))
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+
+	"github.com/tracerun/tdb"
+)
+
+// stringsFlag collects a flag given several times.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func runExport(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", string(tdb.FormatJSONL), "jsonl or csv")
+	var targets stringsFlag
+	fs.Var(&targets, "target", "target to export, can be given several times, all by default")
+	start := fs.Uint("start", 0, "unix time to export slots from")
+	end := fs.Uint("end", 0, "unix time to export slots until, 0 means no end")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 || *start > 1<<32-1 || *end > 1<<32-1 {
+		return errUsage
+	}
+
+	db, err := tdb.OpenWithOptions(positional[0], tdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	filter := tdb.ExportFilter{Targets: targets, Start: uint32(*start), End: uint32(*end)}
+	return db.Export(out, tdb.ExportFormat(*format), filter)
+}
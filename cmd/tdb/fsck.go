@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/tracerun/tdb"
+)
+
+func runFsck(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "fix what can be fixed safely")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 {
+		return errUsage
+	}
+
+	// a repair neither creates a database nor expires the open actions
+	db, err := tdb.OpenWithOptions(positional[0], tdb.Options{ReadOnly: !*repair, MustExist: true, KeepActions: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var report tdb.Report
+	if *repair {
+		report, err = db.Repair(context.Background())
+	} else {
+		report, err = db.Verify(context.Background())
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, pr := range report.Problems {
+		fmt.Fprintln(out, pr)
+	}
+	fmt.Fprintf(out, "%d targets, %d files, %d problems\n", report.Targets, report.Files, len(report.Problems))
+
+	if !report.OK() {
+		return errors.New("database has problems")
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tracerun/tdb"
+)
+
+func runImport(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", string(tdb.FormatJSONL), "jsonl or csv")
+	skip := fs.Bool("skip-existing", false, "skip slots already in the database")
+	create := fs.Bool("create", false, "create the database if the folder holds none")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 2 {
+		return errUsage
+	}
+
+	in := os.Stdin
+	if positional[1] != "-" {
+		if in, err = os.Open(positional[1]); err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+
+	db, err := tdb.OpenWithOptions(positional[0], tdb.Options{MustExist: !*create})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	stats, err := db.Import(in, tdb.ExportFormat(*format), tdb.ImportOptions{SkipExisting: *skip})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%d slots, %d added, %d skipped, %d ignored\n", stats.Records, stats.Added, stats.Skipped, stats.Ignored)
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/tracerun/tdb"
+)
+
+func runInfo(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 {
+		return errUsage
+	}
+
+	db, err := tdb.OpenWithOptions(positional[0], tdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	meta, err := db.Metadata()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return writeJSON(out, meta)
+	}
+
+	offset := meta.ZoneOffset
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "version\t%d\n", meta.Version)
+	fmt.Fprintf(w, "tag\t%s\n", meta.Tag)
+	fmt.Fprintf(w, "create at\t%s\n", formatUnix(meta.CreateAt))
+	fmt.Fprintf(w, "host\t%s\n", meta.Host)
+	fmt.Fprintf(w, "arch\t%s\n", meta.Arch)
+	fmt.Fprintf(w, "username\t%s\n", meta.Username)
+	fmt.Fprintf(w, "os\t%s\n", meta.OS)
+	fmt.Fprintf(w, "zone offset\t%c%02d:%02d (%d)\n", sign, offset/3600, offset%3600/60, meta.ZoneOffset)
+	fmt.Fprintf(w, "zone\t%s\n", meta.Zone)
+	return w.Flush()
+}
@@ -0,0 +1,121 @@
+// Command tdb inspects and maintains a tdb database folder.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+// command is a subcommand of tdb.
+type command struct {
+	name  string
+	usage string
+	run   func(out io.Writer, args []string) error
+}
+
+var commands = []command{
+	{"info", "info [--json] <dir>", runInfo},
+	{"targets", "targets [--json] <dir>", runTargets},
+	{"slots", "slots [--from time] [--to time] [--json] <dir> <target>", runSlots},
+	{"actions", "actions [--json] <dir>", runActions},
+	{"expire", "expire [--json] <dir>", runExpire},
+	{"fsck", "fsck [--repair] <dir>", runFsck},
+	{"export", "export [--format jsonl|csv] [--target name]... [--start unix] [--end unix] <dir>", runExport},
+	{"import", "import [--format jsonl|csv] [--skip-existing] [--create] <dir> <file|->", runImport},
+	{"migrate", "migrate [--to version] [--zone local|utc|fixed] <dir>", runMigrate},
+	{"rebuild-rollups", "rebuild-rollups <dir>", runRebuildRollups},
+	{"rm-target", "rm-target <dir> <target>...", runRmTarget},
+	{"serve", "serve --dir <dir> [--addr host:port] [--token token] [--expire-interval 10s]", runServe},
+}
+
+// errUsage makes tdb print the usage of a command.
+var errUsage = errors.New("wrong usage")
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+		err := c.run(os.Stdout, os.Args[2:])
+		if err == errUsage {
+			fmt.Fprintf(os.Stderr, "usage: tdb %s\n", c.usage)
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tdb %s: %v\n", c.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	usage(os.Stderr)
+	os.Exit(2)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: tdb <command> [arguments]")
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %s\n", c.usage)
+	}
+}
+
+// parseArgs parses flags placed anywhere among the arguments,
+// return the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(ioutil.Discard)
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// writeJSON writes "v" indented for the --json flag of commands.
+func writeJSON(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// formatUnix formats a unix time in the local zone followed by the number.
+func formatUnix(unix uint32) string {
+	return fmt.Sprintf("%s (%d)", time.Unix(int64(unix), 0).Format("2006-01-02 15:04:05 -0700"), unix)
+}
+
+// parseTime parses a unix time, an RFC 3339 time or a date in the local zone.
+func parseTime(s string) (uint32, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		if t, err = time.ParseInLocation("2006-01-02", s, time.Local); err != nil {
+			return 0, fmt.Errorf("%q is not a unix time, an RFC 3339 time or a date", s)
+		}
+	}
+	if t.Unix() < 0 || t.Unix() > 1<<32-1 {
+		return 0, fmt.Errorf("%q is out of range", s)
+	}
+	return uint32(t.Unix()), nil
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracerun/tdb"
+)
+
+func TestParseArgs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "")
+	positional, err := parseArgs(fs, []string{"dir", "--repair", "more"})
+	assert.NoError(t, err, "parse wrong")
+	assert.True(t, *repair, "flag after positional should be parsed")
+	assert.Equal(t, []string{"dir", "more"}, positional, "positional wrong")
+
+	_, err = parseArgs(fs, []string{"--unknown"})
+	assert.Equal(t, errUsage, err, "unknown flag")
+}
+
+func TestFsck(t *testing.T) {
+	folder := "test_fsck"
+	defer os.RemoveAll(folder)
+
+	db, err := tdb.OpenWithOptions(folder, tdb.Options{KeepActions: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	assert.NoError(t, db.AddSlot("a", 1491134201, 20), "add slot wrong")
+	assert.NoError(t, db.AddAction("b", 1491134201), "add action wrong")
+	assert.NoError(t, db.Close(), "close wrong")
+
+	var out bytes.Buffer
+	assert.NoError(t, runFsck(&out, []string{folder}), "fsck wrong")
+	assert.Equal(t, "1 targets, 1 files, 0 problems\n", out.String(), "output wrong")
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(folder, "slots", "orphan"), 0755), "create wrong")
+	out.Reset()
+	assert.Error(t, runFsck(&out, []string{"--repair", folder}), "orphan can't be repaired")
+	assert.Contains(t, out.String(), "orphan-alias", "output wrong")
+
+	// the open action is not expired by the repair
+	out.Reset()
+	assert.NoError(t, runActions(&out, []string{folder}), "actions wrong")
+	assert.Contains(t, out.String(), "(1491134201)", "action should be kept")
+
+	assert.Equal(t, tdb.ErrNotExist, runFsck(&out, []string{"--repair", "not_exist"}), "dir not exist")
+	_, err = os.Stat("not_exist")
+	assert.True(t, os.IsNotExist(err), "dir should not be created")
+	assert.Equal(t, errUsage, runFsck(&out, nil), "dir is needed")
+}
+
+func TestMigrate(t *testing.T) {
+	folder := "test_migrate"
+	defer os.RemoveAll(folder)
+	defer os.RemoveAll(folder + ".v1.bak")
+
+	db, err := tdb.OpenWithOptions(folder, tdb.Options{Version: 1})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	assert.NoError(t, db.AddSlot("a", 1491134201, 20), "add slot wrong")
+	assert.NoError(t, db.Close(), "close wrong")
+
+	var out bytes.Buffer
+	assert.NoError(t, runMigrate(&out, []string{folder}), "migrate wrong")
+	assert.Equal(t, "database is at version 2, zone local\n", out.String(), "output wrong")
+
+	out.Reset()
+	assert.NoError(t, runMigrate(&out, []string{folder, "--zone", "utc"}), "re-bucket wrong")
+	assert.Equal(t, "database is at version 2, zone utc\n", out.String(), "output wrong")
+	defer os.RemoveAll(folder + ".local.bak")
+	assert.Error(t, runMigrate(&out, []string{"--zone", "mars", folder}), "unknown zone")
+
+	out.Reset()
+	assert.NoError(t, runFsck(&out, []string{folder}), "fsck wrong")
+
+	assert.Equal(t, errUsage, runMigrate(&out, []string{"--to", "256", folder}), "version too large")
+}
+
+func TestExport(t *testing.T) {
+	folder := "test_export"
+	defer os.RemoveAll(folder)
+
+	db, err := tdb.Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	assert.NoError(t, db.AddSlot("a", 1491134201, 20), "add slot wrong")
+	assert.NoError(t, db.AddSlot("b", 1491134201, 30), "add slot wrong")
+	assert.NoError(t, db.Close(), "close wrong")
+
+	var out bytes.Buffer
+	assert.NoError(t, runExport(&out, []string{folder, "--format", "csv", "--target", "b", "--start", "1491134000"}), "export wrong")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, []string{"type,target,start,duration", "slot,b,1491134201,30"}, lines[1:], "output wrong")
+
+	out.Reset()
+	assert.NoError(t, runExport(&out, []string{folder}), "export wrong")
+	assert.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 3, "jsonl wrong")
+
+	assert.Error(t, runExport(&out, []string{"--format", "xml", folder}), "unknown format")
+	assert.Equal(t, errUsage, runExport(&out, nil), "dir is needed")
+}
+
+func TestImport(t *testing.T) {
+	folder := "test_import"
+	file := "test_import.csv"
+	defer os.RemoveAll(folder)
+	defer os.Remove(file)
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("target,start,howlong\na,1491134201,20\n"), 0644), "write wrong")
+
+	// a mistyped folder is not turned into a database
+	var out bytes.Buffer
+	assert.Equal(t, tdb.ErrNotExist, runImport(&out, []string{"--format", "csv", folder, file}), "dir not exist")
+	_, err := os.Stat(folder)
+	assert.True(t, os.IsNotExist(err), "dir should not be created")
+
+	assert.NoError(t, runImport(&out, []string{"--format", "csv", "--create", folder, file}), "import wrong")
+	assert.Equal(t, "1 slots, 1 added, 0 skipped, 0 ignored\n", out.String(), "output wrong")
+
+	out.Reset()
+	assert.NoError(t, runImport(&out, []string{"--format", "csv", "--skip-existing", folder, file}), "import wrong")
+	assert.Equal(t, "1 slots, 0 added, 1 skipped, 0 ignored\n", out.String(), "output wrong")
+
+	assert.Error(t, runImport(&out, []string{folder, "not_exist.csv"}), "file not exist")
+	assert.Equal(t, errUsage, runImport(&out, []string{folder}), "file is needed")
+}
+
+func TestRmTarget(t *testing.T) {
+	folder := "test_rm_target"
+	defer os.RemoveAll(folder)
+
+	db, err := tdb.Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	assert.NoError(t, db.AddSlot("a", 1491134201, 20), "add slot wrong")
+	assert.NoError(t, db.AddSlot("b", 1491134201, 30), "add slot wrong")
+	assert.NoError(t, db.Close(), "close wrong")
+
+	var out bytes.Buffer
+	assert.NoError(t, runRmTarget(&out, []string{folder, "a", "b"}), "rm-target wrong")
+	assert.Equal(t, "deleted a\ndeleted b\n", out.String(), "output wrong")
+
+	assert.Error(t, runRmTarget(&out, []string{folder, "a"}), "not exist")
+	assert.Equal(t, errUsage, runRmTarget(&out, []string{folder}), "target is needed")
+	assert.Equal(t, tdb.ErrNotExist, runRmTarget(&out, []string{"not_exist", "a"}), "dir not exist")
+	_, err = os.Stat("not_exist")
+	assert.True(t, os.IsNotExist(err), "dir should not be created")
+}
+
+func TestRebuildRollups(t *testing.T) {
+	folder := "test_rebuild_rollups"
+	defer os.RemoveAll(folder)
+
+	db, err := tdb.Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	assert.NoError(t, db.AddSlot("a", 1491134201, 20), "add slot wrong")
+	assert.NoError(t, db.Close(), "close wrong")
+	assert.NoError(t, os.RemoveAll(filepath.Join(folder, "rollups")), "remove wrong")
+
+	var out bytes.Buffer
+	assert.NoError(t, runRebuildRollups(&out, []string{folder}), "rebuild wrong")
+	assert.Equal(t, "rebuilt rollups of 1 targets\n", out.String(), "output wrong")
+
+	db, err = tdb.OpenWithOptions(folder, tdb.Options{ReadOnly: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rollups, err := db.Rollups("a", 0, 0, tdb.RollupMonth)
+	assert.NoError(t, err, "rollups wrong")
+	assert.Len(t, rollups, 1, "rollups should be rebuilt")
+
+	assert.Equal(t, errUsage, runRebuildRollups(&out, nil), "dir is needed")
+	assert.Equal(t, tdb.ErrNotExist, runRebuildRollups(&out, []string{"not_exist"}), "dir not exist")
+	_, err = os.Stat("not_exist")
+	assert.True(t, os.IsNotExist(err), "dir should not be created")
+}
+
+func TestServe(t *testing.T) {
+	folder := "test_serve"
+	defer os.RemoveAll(folder)
+
+	var out bytes.Buffer
+	assert.Equal(t, errUsage, runServe(&out, nil), "dir is needed")
+	assert.Equal(t, errUsage, runServe(&out, []string{folder}), "dir is a flag")
+	assert.Error(t, runServe(&out, []string{"--dir", folder, "--addr", "not an address"}), "address wrong")
+}
+
+// fixedClock stops the time so actions stay open when the database is closed.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time                         { return time.Time(c) }
+func (c fixedClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// create a database with a slot of "a" and an open action of "b"
+func createInspected(t *testing.T, folder string) {
+	db, err := tdb.OpenWithOptions(folder, tdb.Options{Clock: fixedClock(time.Unix(1491134210, 0))})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	assert.NoError(t, db.AddSlot("a", 1491134201, 20), "add slot wrong")
+	assert.NoError(t, db.AddSlot("a", 1491134301, 40), "add slot wrong")
+	assert.NoError(t, db.AddAction("b", 1491134205), "add action wrong")
+	assert.NoError(t, db.Close(), "close wrong")
+}
+
+func TestInfo(t *testing.T) {
+	folder := "test_info"
+	defer os.RemoveAll(folder)
+	createInspected(t, folder)
+
+	var out bytes.Buffer
+	assert.NoError(t, runInfo(&out, []string{folder}), "info wrong")
+	assert.Contains(t, out.String(), "version      2\n", "output wrong")
+	assert.Contains(t, out.String(), "zone         local\n", "output wrong")
+
+	out.Reset()
+	var meta tdb.Metadata
+	assert.NoError(t, runInfo(&out, []string{"--json", folder}), "info wrong")
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &meta), "json wrong")
+	assert.Equal(t, uint8(2), meta.Version, "version wrong")
+	assert.Len(t, meta.Tag, 26, "tag wrong")
+
+	assert.Equal(t, errUsage, runInfo(&out, nil), "dir is needed")
+	assert.Equal(t, tdb.ErrNotExist, runInfo(&out, []string{"not_exist"}), "dir not exist")
+}
+
+func TestTargets(t *testing.T) {
+	folder := "test_targets"
+	defer os.RemoveAll(folder)
+	createInspected(t, folder)
+
+	var out bytes.Buffer
+	assert.NoError(t, runTargets(&out, []string{folder}), "targets wrong")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 2, "output wrong") {
+		assert.Equal(t, []string{"TARGET", "ALIAS", "FILES"}, strings.Fields(lines[0]), "header wrong")
+		fields := strings.Fields(lines[1])
+		assert.Equal(t, "a", fields[0], "target wrong")
+		assert.Equal(t, "1", fields[2], "files wrong")
+	}
+
+	out.Reset()
+	var stats []tdb.TargetStat
+	assert.NoError(t, runTargets(&out, []string{folder, "--json"}), "targets wrong")
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &stats), "json wrong")
+	assert.Len(t, stats, 1, "stats wrong")
+}
+
+func TestSlots(t *testing.T) {
+	folder := "test_slots"
+	defer os.RemoveAll(folder)
+	createInspected(t, folder)
+
+	var out bytes.Buffer
+	assert.NoError(t, runSlots(&out, []string{folder, "a"}), "slots wrong")
+	assert.Contains(t, out.String(), "2 slots, 1m0s in total\n", "output wrong")
+
+	out.Reset()
+	var slots []tdb.Slot
+	assert.NoError(t, runSlots(&out, []string{"--from", "1491134300", "--json", folder, "a"}), "slots wrong")
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &slots), "json wrong")
+	assert.Equal(t, []tdb.Slot{{Target: "a", Start: 1491134301, Duration: 40}}, slots, "slots wrong")
+
+	out.Reset()
+	slots = nil
+	assert.NoError(t, runSlots(&out, []string{"--to", "2017-04-02T11:57:00Z", "--json", folder, "a"}), "slots wrong")
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &slots), "json wrong")
+	assert.Equal(t, []tdb.Slot{{Target: "a", Start: 1491134201, Duration: 20}}, slots, "slots wrong")
+
+	out.Reset()
+	assert.NoError(t, runSlots(&out, []string{"--json", folder, "none"}), "slots wrong")
+	assert.Equal(t, "[]\n", out.String(), "no slot of an unknown target")
+
+	assert.Error(t, runSlots(&out, []string{"--from", "yesterday", folder, "a"}), "time wrong")
+	assert.Equal(t, errUsage, runSlots(&out, []string{folder}), "target is needed")
+}
+
+func TestActions(t *testing.T) {
+	folder := "test_actions"
+	defer os.RemoveAll(folder)
+	createInspected(t, folder)
+
+	var out bytes.Buffer
+	assert.NoError(t, runActions(&out, []string{folder}), "actions wrong")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 2, "output wrong") {
+		assert.True(t, strings.HasPrefix(lines[1], "b "), "target wrong")
+		assert.Contains(t, lines[1], "(1491134205)", "start wrong")
+	}
+
+	// the action is long expired by the wall clock
+	out.Reset()
+	assert.NoError(t, runExpire(&out, []string{folder}), "expire wrong")
+	assert.Equal(t, "1 actions expired, 0 still open\n", out.String(), "output wrong")
+
+	out.Reset()
+	var actions []action
+	assert.NoError(t, runActions(&out, []string{"--json", folder}), "actions wrong")
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &actions), "json wrong")
+	assert.Empty(t, actions, "actions should be expired")
+
+	out.Reset()
+	assert.NoError(t, runExpire(&out, []string{"--json", folder}), "expire wrong")
+	assert.Equal(t, "{\n  \"expired\": 0,\n  \"open\": 0\n}\n", out.String(), "output wrong")
+
+	assert.Equal(t, tdb.ErrNotExist, runExpire(&out, []string{"not_exist"}), "dir not exist")
+	_, err := os.Stat("not_exist")
+	assert.True(t, os.IsNotExist(err), "dir should not be created")
+}
+
+func TestParseTime(t *testing.T) {
+	unix, err := parseTime("1491134201")
+	assert.NoError(t, err, "parse wrong")
+	assert.Equal(t, uint32(1491134201), unix, "unix wrong")
+
+	unix, err = parseTime("2017-04-02T12:36:41Z")
+	assert.NoError(t, err, "parse wrong")
+	assert.Equal(t, uint32(1491136601), unix, "RFC 3339 wrong")
+
+	unix, err = parseTime("2017-04-02")
+	assert.NoError(t, err, "parse wrong")
+	assert.Equal(t, uint32(time.Date(2017, 4, 2, 0, 0, 0, 0, time.Local).Unix()), unix, "date wrong")
+
+	_, err = parseTime("1900-01-01")
+	assert.Error(t, err, "out of range")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/tracerun/tdb"
+)
+
+func runMigrate(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	to := fs.Uint("to", 0, "version to migrate to, 0 means the latest")
+	zoneName := fs.String("zone", "", "zone to re-bucket slot files in: local, utc or fixed")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 || *to > 255 {
+		return errUsage
+	}
+
+	if err := tdb.Migrate(positional[0], uint8(*to)); err != nil {
+		return err
+	}
+	if *zoneName != "" {
+		zone, err := tdb.ParseZone(*zoneName)
+		if err != nil {
+			return err
+		}
+		if err := tdb.Rebucket(positional[0], zone); err != nil {
+			return err
+		}
+	}
+
+	db, err := tdb.OpenWithOptions(positional[0], tdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	v, err := db.Version()
+	if err != nil {
+		return err
+	}
+	zone, err := db.Zone()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "database is at version %d, zone %s\n", v, zone)
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/tracerun/tdb"
+)
+
+func runRmTarget(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("rm-target", flag.ContinueOnError)
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) < 2 {
+		return errUsage
+	}
+
+	db, err := tdb.OpenWithOptions(positional[0], tdb.Options{MustExist: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, target := range positional[1:] {
+		if err := db.DeleteTarget(target); err != nil {
+			return fmt.Errorf("%s: %v", target, err)
+		}
+		fmt.Fprintf(out, "deleted %s\n", target)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/tracerun/tdb"
+)
+
+func runRebuildRollups(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("rebuild-rollups", flag.ContinueOnError)
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 {
+		return errUsage
+	}
+
+	db, err := tdb.OpenWithOptions(positional[0], tdb.Options{MustExist: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.RebuildRollups(context.Background()); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "rebuilt rollups of %d targets\n", len(db.GetTargets()))
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tracerun/tdb"
+	"github.com/tracerun/tdb/httpapi"
+)
+
+func runServe(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	dir := fs.String("dir", "", "database folder")
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	token := fs.String("token", os.Getenv("TDB_TOKEN"), "bearer token required by requests, $TDB_TOKEN by default")
+	expire := fs.Duration("expire-interval", 10*time.Second, "interval to turn expired actions into slots, 0 disables it")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 0 || *dir == "" {
+		return errUsage
+	}
+
+	db, err := tdb.OpenWithOptions(*dir, tdb.Options{ExpireInterval: *expire})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: httpapi.NewHandler(db, *token)}
+
+	// shut down on interrupt, the database is closed after the requests in flight
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	fmt.Fprintf(out, "serving %s on %s\n", *dir, lis.Addr())
+	if err := srv.Serve(lis); err != http.ErrServerClosed {
+		return err
+	}
+	<-done
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tracerun/tdb"
+)
+
+func runSlots(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("slots", flag.ContinueOnError)
+	from := fs.String("from", "", "unix time, RFC 3339 time or date to list slots from")
+	to := fs.String("to", "", "unix time, RFC 3339 time or date to list slots until, no end by default")
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 2 {
+		return errUsage
+	}
+
+	var start, end uint32
+	if *from != "" {
+		if start, err = parseTime(*from); err != nil {
+			return err
+		}
+	}
+	if *to != "" {
+		if end, err = parseTime(*to); err != nil {
+			return err
+		}
+	}
+
+	db, err := tdb.OpenWithOptions(positional[0], tdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	slots := []tdb.Slot{}
+	it := db.IterSlots(context.Background(), positional[1], start, end, tdb.IterOptions{})
+	for it.Next() {
+		slots = append(slots, it.Slot())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if *asJSON {
+		return writeJSON(out, slots)
+	}
+
+	var total time.Duration
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tDURATION")
+	for _, s := range slots {
+		d := time.Duration(s.Duration) * time.Second
+		fmt.Fprintf(w, "%s\t%s\n", formatUnix(s.Start), d)
+		total += d
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%d slots, %s in total\n", len(slots), total)
+	return nil
+}
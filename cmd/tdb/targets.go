@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/tracerun/tdb"
+)
+
+func runTargets(out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("targets", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 {
+		return errUsage
+	}
+
+	db, err := tdb.OpenWithOptions(positional[0], tdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	stats, err := db.TargetStats()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if stats == nil {
+			stats = []tdb.TargetStat{}
+		}
+		return writeJSON(out, stats)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tALIAS\tFILES")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", s.Target, s.Alias, s.Files)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,66 @@
+package tdb
+
+import (
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// DeleteTarget removes a target with all its slot files and its open action.
+// ErrNotExist is returned if the target has neither slots nor an action.
+func (db *TDB) DeleteTarget(target string) error {
+	if err := db.check(true); err != nil {
+		return err
+	}
+
+	// actions are locked before the target and its alias, like AddAction adding a slot does
+	db.action.contentLock.Lock()
+	err := db.deleteTarget(target)
+	db.action.contentLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	db.log("target deleted", zap.String("target", target))
+	return db.compactActionsIfFull()
+}
+
+// delete the slots and the action of a target, the caller holds the action content lock
+func (db *TDB) deleteTarget(target string) error {
+	// no alias can be created for the target meanwhile
+	unlockTarget := srcLocker.WriteLock(target)
+	defer unlockTarget()
+
+	found := false
+	if b := db.slot.getInfoValue(target); b != nil {
+		aliasedHome := filepath.Join(db.path, slotsFolder, string(b))
+		unlock := srcLocker.WriteLock(aliasedHome)
+		defer unlock()
+
+		// the index entry goes first, an interrupted removal only leaves an orphan folder
+		if err := db.slot.deleteInfo([]string{target}); err != nil {
+			return err
+		}
+		db.targets.remove(target)
+		if err := os.RemoveAll(aliasedHome); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(db.rollupsHome(aliasedHome)); err != nil {
+			return err
+		}
+		found = true
+	}
+
+	_, ok := db.action.content[target]
+	if ok {
+		if err := db.deleteActions([]string{target}); err != nil {
+			return err
+		}
+	}
+
+	if !found && !ok {
+		return ErrNotExist
+	}
+	return nil
+}
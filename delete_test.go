@@ -0,0 +1,143 @@
+package tdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteTarget(t *testing.T) {
+	folder := "test_delete"
+	defer os.RemoveAll(folder)
+
+	start := uint32(1491134201)
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	assert.NoError(t, db.AddSlot("a", start, 20), "add slot wrong")
+	assert.NoError(t, db.AddSlot("b", start, 30), "add slot wrong")
+	assert.NoError(t, db.AddAction("a", start+100), "add action wrong")
+	assert.NoError(t, db.AddAction("c", start+100), "add action wrong")
+	aliasedHome := filepath.Join(folder, slotsFolder, string(db.slot.getInfoValue("a")))
+
+	assert.NoError(t, db.DeleteTarget("a"), "delete wrong")
+	assert.Equal(t, []string{"b"}, db.GetTargets(), "targets wrong")
+	_, err = os.Stat(aliasedHome)
+	assert.True(t, os.IsNotExist(err), "folder should be removed")
+	targets, _, _, err := db.GetActions()
+	assert.NoError(t, err, "get actions wrong")
+	assert.Equal(t, []string{"c"}, targets, "action should be removed")
+	starts, _, err := db.GetSlots("a", 0, 0)
+	assert.NoError(t, err, "get slots wrong")
+	assert.Empty(t, starts, "no slots")
+
+	// only an action
+	assert.NoError(t, db.DeleteTarget("c"), "delete wrong")
+	assert.Equal(t, ErrNotExist, db.DeleteTarget("c"), "not exist")
+
+	// a new alias for the same name
+	assert.NoError(t, db.AddSlot("a", start, 40), "add slot wrong")
+	checkSlots(t, db, "a", [][2]uint32{{start, 40}})
+
+	report, err := db.Verify(context.Background())
+	assert.NoError(t, err, "verify wrong")
+	assert.True(t, report.OK(), "should have no problem: %v", report.Problems)
+}
+
+func TestDeleteTargetConcurrently(t *testing.T) {
+	folder := "test_delete_concurrently"
+	defer os.RemoveAll(folder)
+
+	// every action of "a" turns the previous one into a slot
+	db, err := OpenWithOptions(folder, Options{ActionExpiry: 1})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := uint32(1491134201)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				switch i {
+				case 0:
+					err := db.DeleteTarget("a")
+					assert.True(t, err == nil || err == ErrNotExist, "delete wrong: %v", err)
+				case 1, 2:
+					assert.NoError(t, db.AddAction("a", start+uint32(j)*3), "add action wrong")
+				default:
+					assert.NoError(t, db.AddSlot("a", start+uint32(j), 1), "add slot wrong")
+				}
+			}
+		}(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("deadlock between deleting a target and adding actions")
+	}
+
+	// no slot file is left in a removed folder
+	report, err := db.Verify(context.Background())
+	assert.NoError(t, err, "verify wrong")
+	assert.True(t, report.OK(), "should have no problem: %v", report.Problems)
+}
+
+func TestDeleteTargetLockOrder(t *testing.T) {
+	folder := "test_delete_lock_order"
+	defer os.RemoveAll(folder)
+
+	// closing leaves the action alone, so a failure doesn't hang on the locks
+	db, err := OpenWithOptions(folder, Options{KeepActions: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := uint32(1491134201)
+	assert.NoError(t, db.AddSlot("a", start, 10), "add slot wrong")
+	assert.NoError(t, db.AddAction("a", start+100), "add action wrong")
+
+	// like AddAction turning an action into a slot, the slot is added under the action lock
+	db.action.contentLock.Lock()
+	deleted := make(chan error, 1)
+	go func() {
+		deleted <- db.DeleteTarget("a")
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	added := make(chan error, 1)
+	go func() {
+		added <- db.addSlot("a", start+200, 1)
+	}()
+	select {
+	case err := <-added:
+		assert.NoError(t, err, "add slot wrong")
+		db.action.contentLock.Unlock()
+	case <-time.After(5 * time.Second):
+		db.action.contentLock.Unlock()
+		t.Fatal("deadlock between deleting a target and adding a slot of an action")
+	}
+
+	assert.NoError(t, <-deleted, "delete wrong")
+	assert.Empty(t, db.GetTargets(), "target should be deleted")
+	targets, _, _, err := db.GetActions()
+	assert.NoError(t, err, "get actions wrong")
+	assert.Empty(t, targets, "action should be deleted")
+}
@@ -13,8 +13,9 @@ import (
 
 type fileEncode uint32
 
-func encodeFileFromUnix(unixtime uint32) fileEncode {
-	t := time.Unix(int64(unixtime), 0)
+// encode the half-day file of the unix time, bucketed in the location
+func encodeFileFromUnix(unixtime uint32, loc *time.Location) fileEncode {
+	t := time.Unix(int64(unixtime), 0).In(loc)
 	year, month, day := t.Date()
 	hour := t.Hour()
 	return encodeFile(year, int(month), day, hour)
@@ -82,9 +83,9 @@ func (f fileEncode) path() (string, string) {
 	return folder, fileName
 }
 
-// get original unix time
-func (f fileEncode) origin() uint32 {
-	fileOrigin := time.Date(f.year(), time.Month(f.month()), f.day(), 0, 0, 0, 0, time.Local).Unix()
+// get original unix time in the location
+func (f fileEncode) origin(loc *time.Location) uint32 {
+	fileOrigin := time.Date(f.year(), time.Month(f.month()), f.day(), 0, 0, 0, 0, loc).Unix()
 	if !f.isAM() {
 		fileOrigin = fileOrigin + 43200
 	}
@@ -106,14 +107,14 @@ type fileRange struct {
 
 // create a new fileRange instance
 // startUnix == 0 means from very beginning; endUnix == 0 means to very end.
-func newFileRange(startUnix uint32, endUnix uint32) (*fileRange, error) {
+func newFileRange(startUnix uint32, endUnix uint32, loc *time.Location) (*fileRange, error) {
 	if startUnix != 0 && endUnix != 0 && startUnix > endUnix {
 		return nil, ErrRange
 	}
 
 	one := &fileRange{
-		start: encodeFileFromUnix(startUnix),
-		end:   encodeFileFromUnix(endUnix),
+		start: encodeFileFromUnix(startUnix, loc),
+		end:   encodeFileFromUnix(endUnix, loc),
 	}
 	// set to 0 if startUnix == 0
 	if startUnix == 0 {
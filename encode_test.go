@@ -60,22 +60,22 @@ func TestSortingFileEncode(t *testing.T) {
 }
 
 func TestFileRange(t *testing.T) {
-	fRange, err := newFileRange(0, 0)
+	fRange, err := newFileRange(0, 0, time.Local)
 	assert.NoError(t, err, "error creating file range")
 	assert.Equal(t, fileEncode(0), fRange.start, "start should be 0")
 	assert.Equal(t, fileEncode(math.MaxUint32), fRange.end, "end should be the max uint32")
 
-	fRange, err = newFileRange(2, 1)
+	fRange, err = newFileRange(2, 1, time.Local)
 	assert.Equal(t, ErrRange, err, "should have error when start > end")
 	assert.Nil(t, fRange, "filerange should be nil")
 
-	fRange, err = newFileRange(0, 0)
+	fRange, err = newFileRange(0, 0, time.Local)
 	inRange, err := fRange.folderInRange("badfolder")
 	assert.False(t, inRange, "should not in range.")
 	assert.NotNil(t, err, "can't convert to int")
 
 	// from 201704020 to 201902255 (UTC)
-	fRange, err = newFileRange(1491134201, 1551134201)
+	fRange, err = newFileRange(1491134201, 1551134201, time.Local)
 
 	// test folder range
 	inRange, err = fRange.folderInRange("201712")
@@ -2,6 +2,8 @@ package tdb
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 )
 
 var (
@@ -15,4 +17,39 @@ var (
 	ErrRange = errors.New("range is wrong")
 	// ErrActionValue error for action value field
 	ErrActionValue = errors.New("action value bytes wrong")
+	// ErrClosed the database is closed
+	ErrClosed = errors.New("database is closed")
+	// ErrReadOnly the database is opened read-only
+	ErrReadOnly = errors.New("database is read-only")
+	// ErrVersion the database version is not supported
+	ErrVersion = errors.New("database version is not supported")
+	// ErrTargetExist the target already exists
+	ErrTargetExist = errors.New("target already exists")
+	// ErrMigrating a migration of the database is unfinished
+	ErrMigrating = errors.New("database migration is unfinished, run Migrate again")
 )
+
+// CorruptionError reports a half-day slot file with inconsistent records.
+type CorruptionError struct {
+	Path     string // path of the half-day file without ext
+	Records  int    // consistent records
+	Torn     bool   // the file lengths show a partial write
+	Bad      int    // records failing the checksum
+	Repaired bool   // whether the files are truncated to the consistent records
+}
+
+func (e *CorruptionError) Error() string {
+	var problems []string
+	if e.Torn {
+		problems = append(problems, "is torn")
+	}
+	if e.Bad > 0 {
+		problems = append(problems, fmt.Sprintf("has %d records failing the checksum", e.Bad))
+	}
+
+	msg := fmt.Sprintf("slot file %s %s, %d records are consistent", e.Path, strings.Join(problems, " and "), e.Records)
+	if e.Repaired {
+		msg += ", truncated to them"
+	}
+	return msg
+}
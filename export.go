@@ -0,0 +1,182 @@
+package tdb
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// ExportFormat is a text format of Export.
+type ExportFormat string
+
+// Formats of Export.
+const (
+	// FormatJSONL writes one JSON object per line, the header first.
+	FormatJSONL ExportFormat = "jsonl"
+	// FormatCSV writes the header as a "#" comment line, then a row of column names.
+	FormatCSV ExportFormat = "csv"
+)
+
+// Types of exported records.
+const (
+	RecordSlot   = "slot"
+	RecordAction = "action"
+)
+
+var csvColumns = []string{"type", "target", "start", "duration"}
+
+// ExportHeader is the metadata of the exported database.
+type ExportHeader struct {
+	Tag        string `json:"tag"`
+	Host       string `json:"host"`
+	Username   string `json:"username"`
+	ZoneOffset int32  `json:"zone_offset"`
+	Zone       string `json:"zone"`
+	Version    uint8  `json:"version"`
+}
+
+// Record is an exported slot, or an open action lasting "Duration" seconds so far.
+type Record struct {
+	Type     string `json:"type"`
+	Target   string `json:"target"`
+	Start    uint32 `json:"start"`
+	Duration uint32 `json:"duration"`
+}
+
+// ExportFilter selects the records to export.
+type ExportFilter struct {
+	Targets []string // all targets if empty
+	Start   uint32   // records starting from
+	End     uint32   // records starting until, 0 means no end
+}
+
+func (f ExportFilter) inRange(start uint32) bool {
+	end := f.End
+	if end == 0 {
+		end = math.MaxUint32
+	}
+	return start >= f.Start && start <= end
+}
+
+// Export writes the header and every slot and open action matching the filter to "w",
+// ordered by target and start.
+func (db *TDB) Export(w io.Writer, format ExportFormat, filter ExportFilter) error {
+	if err := db.check(false); err != nil {
+		return err
+	}
+
+	header, err := db.exportHeader()
+	if err != nil {
+		return err
+	}
+
+	var write func(r Record) error
+	var flush func() error
+	switch format {
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(struct {
+			Type string `json:"type"`
+			ExportHeader
+		}{"header", header}); err != nil {
+			return err
+		}
+		write = func(r Record) error { return enc.Encode(r) }
+		flush = func() error { return nil }
+	case FormatCSV:
+		_, err := fmt.Fprintf(w, "# tag=%s host=%s username=%s zone_offset=%d zone=%s version=%d\n",
+			header.Tag, header.Host, header.Username, header.ZoneOffset, header.Zone, header.Version)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvColumns); err != nil {
+			return err
+		}
+		write = func(r Record) error {
+			return cw.Write([]string{r.Type, r.Target, strconv.FormatUint(uint64(r.Start), 10), strconv.FormatUint(uint64(r.Duration), 10)})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	// sorted on a copy, the filter of the caller is left alone
+	targets := sortedStrings(filter.Targets...)
+	if len(targets) == 0 {
+		targets = db.GetTargets()
+	}
+
+	for _, target := range targets {
+		starts, slots, err := db.GetSlots(target, filter.Start, filter.End)
+		if err != nil {
+			return err
+		}
+		var records []Record
+		for i := range starts {
+			for j := range starts[i] {
+				records = append(records, Record{RecordSlot, target, starts[i][j], slots[i][j]})
+			}
+		}
+		sort.SliceStable(records, func(i, j int) bool { return records[i].Start < records[j].Start })
+		for _, r := range records {
+			if err := write(r); err != nil {
+				return err
+			}
+		}
+	}
+
+	wanted := make(map[string]bool)
+	for _, target := range filter.Targets {
+		wanted[target] = true
+	}
+	actions, starts, lasts, err := db.getActions()
+	if err != nil {
+		return err
+	}
+	var records []Record
+	for i, target := range actions {
+		if len(wanted) > 0 && !wanted[target] || !filter.inRange(starts[i]) {
+			continue
+		}
+		records = append(records, Record{RecordAction, target, starts[i], lasts[i] - starts[i]})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Target < records[j].Target })
+	for _, r := range records {
+		if err := write(r); err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
+func (db *TDB) exportHeader() (ExportHeader, error) {
+	var h ExportHeader
+	var err error
+	if h.Tag, err = db.Tag(); err != nil {
+		return h, err
+	}
+	if h.Host, err = db.Host(); err != nil {
+		return h, err
+	}
+	if h.Username, err = db.Username(); err != nil {
+		return h, err
+	}
+	if h.ZoneOffset, err = db.ZoneOffset(); err != nil {
+		return h, err
+	}
+	zone, err := db.Zone()
+	if err != nil {
+		return h, err
+	}
+	h.Zone = zone.String()
+	h.Version, err = db.Version()
+	return h, err
+}
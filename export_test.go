@@ -0,0 +1,55 @@
+package tdb
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	folder := "test_export"
+	defer os.RemoveAll(folder)
+
+	start := uint32(1491134201)
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	assert.NoError(t, db.AddSlot("b", start+50, 10), "add slot wrong")
+	assert.NoError(t, db.AddSlot("b", start, 20), "add slot wrong")
+	assert.NoError(t, db.AddSlot("a", start+86400, 30), "add slot wrong")
+	assert.NoError(t, db.AddAction("c", start+100), "add action wrong")
+	assert.NoError(t, db.AddAction("c", start+110), "add action wrong")
+
+	tag, err := db.Tag()
+	assert.NoError(t, err, "tag wrong")
+
+	var buf bytes.Buffer
+	assert.NoError(t, db.Export(&buf, FormatJSONL, ExportFilter{}), "export wrong")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 5, "lines wrong") {
+		assert.Contains(t, lines[0], `"type":"header","tag":"`+tag+`"`, "header wrong")
+		assert.Equal(t, `{"type":"slot","target":"a","start":1491220601,"duration":30}`, lines[1], "slot wrong")
+		assert.Equal(t, `{"type":"slot","target":"b","start":1491134201,"duration":20}`, lines[2], "slot should be sorted")
+		assert.Equal(t, `{"type":"slot","target":"b","start":1491134251,"duration":10}`, lines[3], "slot wrong")
+		assert.Equal(t, `{"type":"action","target":"c","start":1491134301,"duration":10}`, lines[4], "action wrong")
+	}
+
+	buf.Reset()
+	filter := ExportFilter{Targets: []string{"c", "b"}, Start: start + 10, End: start + 200}
+	assert.NoError(t, db.Export(&buf, FormatCSV, filter), "export wrong")
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 4, "lines wrong") {
+		assert.True(t, strings.HasPrefix(lines[0], "# tag="+tag+" host="), "header wrong")
+		assert.Equal(t, "type,target,start,duration", lines[1], "columns wrong")
+		assert.Equal(t, "slot,b,1491134251,10", lines[2], "slot wrong")
+		assert.Equal(t, "action,c,1491134301,10", lines[3], "action wrong")
+	}
+	assert.Equal(t, []string{"c", "b"}, filter.Targets, "targets of the filter should be kept")
+
+	assert.Error(t, db.Export(&buf, ExportFormat("xml"), filter), "unknown format")
+}
@@ -0,0 +1,81 @@
+package tdb
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// slotRecord is a slot stored in a half-day file.
+type slotRecord struct {
+	offset  uint16 // seconds since the origin of the half-day file
+	howlong uint32
+}
+
+// slotFormat is an on-disk layout of half-day slot files.
+// "baseName" is the path of a half-day file without ext, locking is left to the callers.
+type slotFormat interface {
+	// version of the database using this format
+	version() uint8
+	// file extensions of a half-day file, the first one is used to list the files
+	exts() []string
+	// read the consistent records, a *CorruptionError is returned with them if some are not
+	read(baseName string) ([]slotRecord, error)
+	// append records, torn files are truncated first and reported with a repaired *CorruptionError
+	append(baseName string, records []slotRecord, sync bool) error
+	// replace all records of the file
+	write(baseName string, records []slotRecord) error
+	// truncate a torn file to the last consistent record, return a repaired *CorruptionError if it was
+	repair(baseName string) (*CorruptionError, error)
+}
+
+// get the slot format of a database version
+func formatOf(v uint8) (slotFormat, error) {
+	switch v {
+	case 1:
+		return formatV1{}, nil
+	case 2:
+		return formatV2{}, nil
+	}
+	return nil, ErrVersion
+}
+
+// readSlots reads all slots of a half-day file and returns their unix time starts.
+// If some records are not consistent, the others are returned with a *CorruptionError.
+func readSlots(format slotFormat, aliasedHome string, file fileEncode, loc *time.Location) ([]uint32, []uint32, error) {
+	unlock := srcLocker.ReadLock(encodeAliasAndFile(aliasedHome, file))
+	records, err := format.read(slotBaseName(aliasedHome, file))
+	unlock()
+	if records == nil {
+		return nil, nil, err
+	}
+
+	origin := file.origin(loc)
+	starts := make([]uint32, len(records))
+	slots := make([]uint32, len(records))
+	for i, r := range records {
+		starts[i] = origin + uint32(r.offset)
+		slots[i] = r.howlong
+	}
+	return starts, slots, err
+}
+
+// appendSlots appends records to a half-day file, create the folder if not exist.
+func appendSlots(format slotFormat, aliasedHome string, file fileEncode, records []slotRecord, sync bool) error {
+	subFolder, _ := file.path()
+
+	// create folder if not exist
+	if err := createFolder(filepath.Join(aliasedHome, subFolder)); err != nil {
+		return err
+	}
+
+	unlock := srcLocker.WriteLock(encodeAliasAndFile(aliasedHome, file))
+	defer unlock()
+
+	return format.append(slotBaseName(aliasedHome, file), records, sync)
+}
+
+// get the path of a half-day file without ext
+func slotBaseName(aliasedHome string, file fileEncode) string {
+	subFolder, fileName := file.path()
+	return filepath.Join(aliasedHome, subFolder, fileName)
+}
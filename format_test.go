@@ -0,0 +1,101 @@
+package tdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatOf(t *testing.T) {
+	f, err := formatOf(1)
+	assert.NoError(t, err, "v1 should be supported")
+	assert.Equal(t, uint8(1), f.version(), "version wrong")
+
+	f, err = formatOf(2)
+	assert.NoError(t, err, "v2 should be supported")
+	assert.Equal(t, uint8(2), f.version(), "version wrong")
+
+	_, err = formatOf(3)
+	assert.Equal(t, ErrVersion, err, "v3 should not be supported")
+}
+
+func TestConsistentRecords(t *testing.T) {
+	assert.Equal(t, 0, consistentRecords(0, 0), "empty files")
+	assert.Equal(t, 2, consistentRecords(4, 8), "files in step")
+	assert.Equal(t, 2, consistentRecords(6, 8), "idx ahead")
+	assert.Equal(t, 1, consistentRecords(4, 7), "slt partially written")
+}
+
+func TestFormats(t *testing.T) {
+	folder := "test_formats"
+	defer os.RemoveAll(folder)
+	assert.NoError(t, createFolder(folder), "create wrong")
+
+	records := []slotRecord{{0, 20}, {100, 5}, {43199, 1}}
+	for _, f := range []slotFormat{formatV1{}, formatV2{}} {
+		baseName := filepath.Join(folder, string('0'+f.version()))
+
+		_, err := f.read(baseName)
+		assert.True(t, os.IsNotExist(err), "should not exist")
+
+		assert.NoError(t, f.append(baseName, records[:1], false), "append wrong")
+		assert.NoError(t, f.append(baseName, records[1:], true), "append wrong")
+		read, err := f.read(baseName)
+		assert.NoError(t, err, "read wrong")
+		assert.Equal(t, records, read, "records wrong")
+
+		assert.NoError(t, f.write(baseName, records[1:]), "write wrong")
+		read, err = f.read(baseName)
+		assert.NoError(t, err, "read wrong")
+		assert.Equal(t, records[1:], read, "records wrong")
+
+		torn, err := f.repair(baseName)
+		assert.NoError(t, err, "repair wrong")
+		assert.Nil(t, torn, "should not be torn")
+	}
+}
+
+func TestFormatV2Corruption(t *testing.T) {
+	folder := "test_format_v2"
+	defer os.RemoveAll(folder)
+	assert.NoError(t, createFolder(folder), "create wrong")
+
+	f := formatV2{}
+	baseName := filepath.Join(folder, "005")
+	records := []slotRecord{{0, 20}, {100, 5}}
+	assert.NoError(t, f.append(baseName, records, false), "append wrong")
+
+	// partial record at the end
+	assert.NoError(t, appendToFile(baseName+recordExt, []byte{1, 2, 3}, false), "append wrong")
+	read, err := f.read(baseName)
+	assert.Equal(t, records, read, "records wrong")
+	if assert.IsType(t, &CorruptionError{}, err, "should be corrupted") {
+		cerr := err.(*CorruptionError)
+		assert.True(t, cerr.Torn, "should be torn")
+		assert.Equal(t, 0, cerr.Bad, "should have no bad record")
+	}
+
+	// appending repairs first
+	err = f.append(baseName, []slotRecord{{200, 1}}, false)
+	if assert.IsType(t, &CorruptionError{}, err, "repair should be reported") {
+		assert.True(t, err.(*CorruptionError).Repaired, "should be repaired")
+	}
+	records = append(records, slotRecord{200, 1})
+	read, err = f.read(baseName)
+	assert.NoError(t, err, "read wrong")
+	assert.Equal(t, records, read, "records wrong")
+
+	// bit rot is detected by the checksum
+	b := encodeRecords(records)
+	b[recordBytes+2] ^= 0x10
+	assert.NoError(t, writeFile(baseName+recordExt, b, false), "write wrong")
+	read, err = f.read(baseName)
+	assert.Equal(t, []slotRecord{records[0], records[2]}, read, "bad record should be skipped")
+	if assert.IsType(t, &CorruptionError{}, err, "should be corrupted") {
+		cerr := err.(*CorruptionError)
+		assert.False(t, cerr.Torn, "should not be torn")
+		assert.Equal(t, 1, cerr.Bad, "should have a bad record")
+	}
+}
@@ -0,0 +1,139 @@
+package tdb
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	slotBytes  = 4
+	indexBytes = 2
+
+	offsetExt = ".idx"
+	slotExt   = ".slt"
+)
+
+// formatV1 splits a half-day file into an idx file of uint16 offsets and a slt file of uint32 slots.
+type formatV1 struct{}
+
+func (formatV1) version() uint8 {
+	return 1
+}
+
+func (formatV1) exts() []string {
+	return []string{offsetExt, slotExt}
+}
+
+func (formatV1) read(baseName string) ([]slotRecord, error) {
+	// read offset index file
+	offsetB, err := ioutil.ReadFile(baseName + offsetExt)
+	if err != nil {
+		return nil, err
+	}
+
+	// read slot file, it may not exist if a crash happened right after creating the index
+	slotB, err := ioutil.ReadFile(baseName + slotExt)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	count := consistentRecords(int64(len(offsetB)), int64(len(slotB)))
+	records := make([]slotRecord, count)
+	for i := range records {
+		records[i].offset = binary.LittleEndian.Uint16(offsetB[i*indexBytes:])
+		records[i].howlong = binary.LittleEndian.Uint32(slotB[i*slotBytes:])
+	}
+
+	if len(offsetB) != count*indexBytes || len(slotB) != count*slotBytes {
+		return records, &CorruptionError{Path: baseName, Records: count, Torn: true}
+	}
+	return records, nil
+}
+
+func (f formatV1) append(baseName string, records []slotRecord, sync bool) error {
+	// keep the files in step before appending
+	torn, err := f.repair(baseName)
+	if err != nil {
+		return err
+	}
+
+	offsetB, slotB := encodeV1(records)
+	if err := appendToFile(baseName+offsetExt, offsetB, sync); err != nil {
+		return err
+	}
+	if err := appendToFile(baseName+slotExt, slotB, sync); err != nil {
+		return err
+	}
+
+	if torn != nil {
+		return torn
+	}
+	return nil
+}
+
+// write replaces the idx file and then the slt file.
+// The pair can't be replaced at once, a crash in between leaves them out of step.
+func (formatV1) write(baseName string, records []slotRecord) error {
+	offsetB, slotB := encodeV1(records)
+	if err := replaceFile(baseName+offsetExt, offsetB); err != nil {
+		return err
+	}
+	return replaceFile(baseName+slotExt, slotB)
+}
+
+func (formatV1) repair(baseName string) (*CorruptionError, error) {
+	return truncateTorn(baseName)
+}
+
+func encodeV1(records []slotRecord) ([]byte, []byte) {
+	offsetB := make([]byte, len(records)*indexBytes)
+	slotB := make([]byte, len(records)*slotBytes)
+	for i, r := range records {
+		binary.LittleEndian.PutUint16(offsetB[i*indexBytes:], r.offset)
+		binary.LittleEndian.PutUint32(slotB[i*slotBytes:], r.howlong)
+	}
+	return offsetB, slotB
+}
+
+// the number of records both the idx and slt files hold completely
+func consistentRecords(idxLen, slotLen int64) int {
+	count := idxLen / indexBytes
+	if n := slotLen / slotBytes; n < count {
+		count = n
+	}
+	return int(count)
+}
+
+// truncateTorn truncates the idx and slt files of a half-day to the last consistent record.
+// It returns a repaired *CorruptionError if the files were out of step.
+func truncateTorn(baseName string) (*CorruptionError, error) {
+	idxName := baseName + offsetExt
+	slotName := baseName + slotExt
+
+	idxLen, err := fileSize(idxName)
+	if err != nil {
+		return nil, err
+	}
+	slotLen, err := fileSize(slotName)
+	if err != nil {
+		return nil, err
+	}
+
+	count := consistentRecords(idxLen, slotLen)
+	if idxLen == int64(count*indexBytes) && slotLen == int64(count*slotBytes) {
+		return nil, nil
+	}
+
+	if idxLen != int64(count*indexBytes) {
+		if err := os.Truncate(idxName, int64(count*indexBytes)); err != nil {
+			return nil, err
+		}
+	}
+	if slotLen != int64(count*slotBytes) {
+		if err := os.Truncate(slotName, int64(count*slotBytes)); err != nil {
+			return nil, err
+		}
+	}
+	return &CorruptionError{Path: baseName, Records: count, Torn: true, Repaired: true}, nil
+}
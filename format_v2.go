@@ -0,0 +1,111 @@
+package tdb
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	recordExt   = ".rec"
+	recordBytes = indexBytes + slotBytes + 4 // offset, slot and crc32 of both
+)
+
+// formatV2 keeps a half-day file in a single rec file of fixed-size records,
+// each of them is checked by a crc32.
+type formatV2 struct{}
+
+func (formatV2) version() uint8 {
+	return 2
+}
+
+func (formatV2) exts() []string {
+	return []string{recordExt}
+}
+
+func (formatV2) read(baseName string) ([]slotRecord, error) {
+	b, err := ioutil.ReadFile(baseName + recordExt)
+	if err != nil {
+		return nil, err
+	}
+
+	count := len(b) / recordBytes
+	records := make([]slotRecord, 0, count)
+	bad := 0
+	for i := 0; i < count; i++ {
+		r, ok := decodeRecord(b[i*recordBytes:])
+		if !ok {
+			bad++
+			continue
+		}
+		records = append(records, r)
+	}
+
+	torn := len(b) != count*recordBytes
+	if torn || bad > 0 {
+		return records, &CorruptionError{Path: baseName, Records: len(records), Torn: torn, Bad: bad}
+	}
+	return records, nil
+}
+
+func (f formatV2) append(baseName string, records []slotRecord, sync bool) error {
+	torn, err := f.repair(baseName)
+	if err != nil {
+		return err
+	}
+
+	if err := appendToFile(baseName+recordExt, encodeRecords(records), sync); err != nil {
+		return err
+	}
+
+	if torn != nil {
+		return torn
+	}
+	return nil
+}
+
+func (formatV2) write(baseName string, records []slotRecord) error {
+	return replaceFile(baseName+recordExt, encodeRecords(records))
+}
+
+// repair truncates a partially written record at the end of the file.
+// Records failing the checksum can't be repaired, they are skipped while reading.
+func (formatV2) repair(baseName string) (*CorruptionError, error) {
+	size, err := fileSize(baseName + recordExt)
+	if err != nil {
+		return nil, err
+	}
+
+	count := size / recordBytes
+	if size == count*recordBytes {
+		return nil, nil
+	}
+	if err := os.Truncate(baseName+recordExt, count*recordBytes); err != nil {
+		return nil, err
+	}
+	return &CorruptionError{Path: baseName, Records: int(count), Torn: true, Repaired: true}, nil
+}
+
+func encodeRecords(records []slotRecord) []byte {
+	b := make([]byte, len(records)*recordBytes)
+	for i, r := range records {
+		one := b[i*recordBytes : (i+1)*recordBytes]
+		binary.LittleEndian.PutUint16(one, r.offset)
+		binary.LittleEndian.PutUint32(one[indexBytes:], r.howlong)
+		binary.LittleEndian.PutUint32(one[indexBytes+slotBytes:], crc32.ChecksumIEEE(one[:indexBytes+slotBytes]))
+	}
+	return b
+}
+
+// decode a record, return false if the checksum doesn't match
+func decodeRecord(b []byte) (slotRecord, bool) {
+	sum := binary.LittleEndian.Uint32(b[indexBytes+slotBytes:])
+	if crc32.ChecksumIEEE(b[:indexBytes+slotBytes]) != sum {
+		return slotRecord{}, false
+	}
+	return slotRecord{
+		offset:  binary.LittleEndian.Uint16(b),
+		howlong: binary.LittleEndian.Uint32(b[indexBytes:]),
+	}, true
+}
@@ -0,0 +1,240 @@
+// Package httpapi serves a TDB over HTTP with JSON bodies.
+//
+//	POST /actions                          {"target": "a", "ts": 1491134201}, ts defaults to the database clock
+//	GET  /targets                          {"targets": ["a"]}
+//	GET  /slots?target=&start=&end=
+//	GET  /aggregate?target=&start=&end=&bucket=hour|day|week|month
+//	GET  /meta
+//	POST /expire
+//
+// Errors are replied as {"error": "..."} with a status code following the error.
+package httpapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tracerun/tdb"
+)
+
+// Handler serves the HTTP API of a database.
+type Handler struct {
+	db    *tdb.TDB
+	token string
+	mux   *http.ServeMux
+}
+
+// NewHandler serves "db", which is not closed by the handler.
+// Requests need the header "Authorization: Bearer <token>" unless "token" is empty.
+func NewHandler(db *tdb.TDB, token string) *Handler {
+	h := &Handler{db: db, token: token, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/actions", h.method(http.MethodPost, h.addAction))
+	h.mux.HandleFunc("/targets", h.method(http.MethodGet, h.getTargets))
+	h.mux.HandleFunc("/slots", h.method(http.MethodGet, h.getSlots))
+	h.mux.HandleFunc("/aggregate", h.method(http.MethodGet, h.aggregate))
+	h.mux.HandleFunc("/meta", h.method(http.MethodGet, h.getMeta))
+	h.mux.HandleFunc("/expire", h.method(http.MethodPost, h.expire))
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token != "" {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(h.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			replyError(w, http.StatusUnauthorized, fmt.Errorf("token is wrong"))
+			return
+		}
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// only allow requests of "method" to "fn"
+func (h *Handler) method(method string, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			replyError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+			return
+		}
+		fn(w, r)
+	}
+}
+
+type actionRequest struct {
+	Target string `json:"target"`
+	Ts     uint32 `json:"ts"`
+}
+
+func (h *Handler) addAction(w http.ResponseWriter, r *http.Request) {
+	var req actionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		replyError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Target == "" {
+		replyError(w, http.StatusBadRequest, fmt.Errorf("target is empty"))
+		return
+	}
+	if req.Ts == 0 {
+		req.Ts = uint32(h.db.Now().Unix())
+	}
+
+	if err := h.db.AddAction(req.Target, req.Ts); err != nil {
+		replyDBError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) getTargets(w http.ResponseWriter, r *http.Request) {
+	reply(w, map[string][]string{"targets": h.db.GetTargets()})
+}
+
+type slot struct {
+	Start    uint32 `json:"start"`
+	Duration uint32 `json:"duration"`
+}
+
+type slotsReply struct {
+	Target string `json:"target"`
+	Slots  []slot `json:"slots"`
+}
+
+// GET /slots, the target is a query parameter as it is often a path or a URL
+func (h *Handler) getSlots(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		replyError(w, http.StatusBadRequest, fmt.Errorf("target is empty"))
+		return
+	}
+
+	start, end, err := parseRange(r)
+	if err != nil {
+		replyError(w, http.StatusBadRequest, err)
+		return
+	}
+	starts, slots, err := h.db.GetSlots(target, start, end)
+	if err != nil {
+		replyDBError(w, err)
+		return
+	}
+
+	resp := slotsReply{Target: target, Slots: []slot{}}
+	for i := range starts {
+		for j := range starts[i] {
+			resp.Slots = append(resp.Slots, slot{starts[i][j], slots[i][j]})
+		}
+	}
+	reply(w, resp)
+}
+
+type aggregateReply struct {
+	Bucket string                   `json:"bucket"`
+	Totals map[string][]bucketTotal `json:"totals"`
+}
+
+type bucketTotal struct {
+	Start   uint32 `json:"start"`
+	Seconds uint32 `json:"seconds"`
+}
+
+// GET /aggregate, the targets are given by "target" several times, all by default
+func (h *Handler) aggregate(w http.ResponseWriter, r *http.Request) {
+	start, end, err := parseRange(r)
+	if err != nil {
+		replyError(w, http.StatusBadRequest, err)
+		return
+	}
+	query := r.URL.Query()
+	bucket := tdb.BucketDay
+	if name := query.Get("bucket"); name != "" {
+		if bucket, err = tdb.ParseBucket(name); err != nil {
+			replyError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	result, err := h.db.Aggregate(query["target"], start, end, bucket)
+	if err != nil {
+		replyDBError(w, err)
+		return
+	}
+
+	resp := aggregateReply{Bucket: bucket.String(), Totals: make(map[string][]bucketTotal)}
+	for target, totals := range result {
+		list := make([]bucketTotal, len(totals))
+		for i, t := range totals {
+			list[i] = bucketTotal{t.Start, t.Seconds}
+		}
+		resp.Totals[target] = list
+	}
+	reply(w, resp)
+}
+
+func (h *Handler) getMeta(w http.ResponseWriter, r *http.Request) {
+	meta, err := h.db.Metadata()
+	if err != nil {
+		replyDBError(w, err)
+		return
+	}
+	reply(w, meta)
+}
+
+func (h *Handler) expire(w http.ResponseWriter, r *http.Request) {
+	if err := h.db.CheckExpirations(); err != nil {
+		replyDBError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parse the "start" and "end" query parameters, from 0 to the end of time if not given
+func parseRange(r *http.Request) (uint32, uint32, error) {
+	values := [2]uint32{0, math.MaxUint32}
+	for i, name := range []string{"start", "end"} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s is not a unix time: %q", name, v)
+		}
+		values[i] = uint32(n)
+	}
+	return values[0], values[1], nil
+}
+
+func reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func replyError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// reply the errors of the database with the status code following them
+func replyDBError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch err {
+	case tdb.ErrClosed:
+		code = http.StatusServiceUnavailable
+	case tdb.ErrReadOnly:
+		code = http.StatusForbidden
+	case tdb.ErrRange, tdb.ErrActionValue:
+		code = http.StatusBadRequest
+	case tdb.ErrNotExist:
+		code = http.StatusNotFound
+	}
+	replyError(w, code, err)
+}
@@ -0,0 +1,164 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracerun/tdb"
+)
+
+// send a request to "h" and decode the JSON reply into "v" if not nil
+func do(t *testing.T, h http.Handler, method, url, body string, v interface{}) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if v != nil {
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(v), "decode reply wrong")
+	}
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	folder := "test_httpapi"
+	defer os.RemoveAll(folder)
+
+	db, err := tdb.Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	h := NewHandler(db, "")
+
+	w := do(t, h, "POST", "/actions", `{"target": "a", "ts": 1491134201}`, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code, "add action wrong")
+	w = do(t, h, "POST", "/actions", `{"target": "a", "ts": 1491134210}`, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code, "add action wrong")
+
+	// the actions are long expired
+	w = do(t, h, "POST", "/expire", "", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code, "expire wrong")
+	assert.NoError(t, db.AddSlot("b/c", 1491134300, 30), "add slot wrong")
+	assert.NoError(t, db.AddSlot("/home/me/x.go", 1491134400, 40), "add slot wrong")
+
+	var targets struct{ Targets []string }
+	w = do(t, h, "GET", "/targets", "", &targets)
+	assert.Equal(t, http.StatusOK, w.Code, "get targets wrong")
+	assert.Equal(t, []string{"/home/me/x.go", "a", "b/c"}, targets.Targets, "targets wrong")
+
+	var slots slotsReply
+	w = do(t, h, "GET", "/slots?target=a&start=1491134000&end=1491135000", "", &slots)
+	assert.Equal(t, http.StatusOK, w.Code, "get slots wrong")
+	assert.Equal(t, slotsReply{"a", []slot{{1491134201, 9}}}, slots, "slots wrong")
+
+	// the target may have slashes
+	w = do(t, h, "GET", "/slots?target=b/c", "", &slots)
+	assert.Equal(t, http.StatusOK, w.Code, "get slots wrong")
+	assert.Equal(t, slotsReply{"b/c", []slot{{1491134300, 30}}}, slots, "slots wrong")
+	w = do(t, h, "GET", "/slots?target="+url.QueryEscape("/home/me/x.go"), "", &slots)
+	assert.Equal(t, http.StatusOK, w.Code, "get slots wrong")
+	assert.Equal(t, slotsReply{"/home/me/x.go", []slot{{1491134400, 40}}}, slots, "slots wrong")
+
+	var agg aggregateReply
+	w = do(t, h, "GET", "/aggregate?target=a&target=b/c&bucket=hour", "", &agg)
+	assert.Equal(t, http.StatusOK, w.Code, "aggregate wrong")
+	assert.Equal(t, "hour", agg.Bucket, "bucket wrong")
+	totals, err := db.Aggregate(nil, 0, 0, tdb.BucketHour)
+	assert.NoError(t, err, "aggregate wrong")
+	for _, target := range []string{"a", "b/c"} {
+		if assert.Len(t, agg.Totals[target], 1, "totals wrong") {
+			assert.Equal(t, totals[target][0].Start, agg.Totals[target][0].Start, "bucket start wrong")
+			assert.Equal(t, totals[target][0].Seconds, agg.Totals[target][0].Seconds, "seconds wrong")
+		}
+	}
+
+	var meta tdb.Metadata
+	w = do(t, h, "GET", "/meta", "", &meta)
+	assert.Equal(t, http.StatusOK, w.Code, "get meta wrong")
+	expected, err := db.Metadata()
+	assert.NoError(t, err, "metadata wrong")
+	assert.Equal(t, expected, meta, "meta wrong")
+
+	// wrong requests
+	w = do(t, h, "GET", "/actions", "", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code, "method should be refused")
+	w = do(t, h, "POST", "/actions", `{"ts": 1491134201}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "empty target should be refused")
+	w = do(t, h, "POST", "/actions", `{`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "wrong body should be refused")
+	w = do(t, h, "GET", "/slots?target=a&start=yesterday", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "wrong start should be refused")
+	w = do(t, h, "GET", "/slots?target=a&start=10&end=5", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "wrong range should be refused")
+	w = do(t, h, "GET", "/aggregate?bucket=fortnight", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "wrong bucket should be refused")
+	w = do(t, h, "GET", "/slots", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "empty target should be refused")
+	w = do(t, h, "GET", "/targets/a", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code, "unknown path")
+
+	assert.NoError(t, db.Close(), "close wrong")
+	var reply struct{ Error string }
+	w = do(t, h, "POST", "/expire", "", &reply)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "closed database should be unavailable")
+	assert.Equal(t, tdb.ErrClosed.Error(), reply.Error, "error wrong")
+}
+
+// fixedClock stops the time at a unix time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time                         { return time.Time(c) }
+func (c fixedClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func TestDefaultTs(t *testing.T) {
+	folder := "test_httpapi_ts"
+	defer os.RemoveAll(folder)
+
+	db, err := tdb.OpenWithOptions(folder, tdb.Options{Clock: fixedClock(time.Unix(1491134201, 0))})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	h := NewHandler(db, "")
+
+	// the action starts at the time of the database clock
+	w := do(t, h, "POST", "/actions", `{"target": "a"}`, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code, "add action wrong")
+	targets, starts, _, err := db.GetActions()
+	assert.NoError(t, err, "get actions wrong")
+	assert.Equal(t, []string{"a"}, targets, "targets wrong")
+	assert.Equal(t, []uint32{1491134201}, starts, "ts should default to the clock")
+}
+
+func TestToken(t *testing.T) {
+	folder := "test_httpapi_token"
+	defer os.RemoveAll(folder)
+
+	db, err := tdb.Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	h := NewHandler(db, "secret")
+
+	w := do(t, h, "GET", "/targets", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "no token should be refused")
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"), "challenge wrong")
+
+	req := httptest.NewRequest("GET", "/targets", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "wrong token should be refused")
+
+	req = httptest.NewRequest("GET", "/targets", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "token should be accepted")
+}
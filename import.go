@@ -0,0 +1,284 @@
+package tdb
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// records read before they are written
+const importBatch = 10000
+
+// ImportOptions of Import.
+type ImportOptions struct {
+	SkipExisting bool // skip records with the same start and duration as an existing slot
+}
+
+// ImportStats of Import.
+type ImportStats struct {
+	Records int // slot records read
+	Added   int // slots added
+	Skipped int // slots skipped as existing
+	Ignored int // records not slots, like headers and actions
+}
+
+// Import loads the slot records of an export into the database.
+// A CSV needs a row of column names with "target", "start" and "duration" or "howlong",
+// the "type" column is optional. Likewise a JSON Lines record without "type" is a slot
+// and may give "howlong" for "duration". Records are grouped by target and half-day file,
+// so every file is written once per batch.
+func (db *TDB) Import(r io.Reader, format ExportFormat, opts ImportOptions) (ImportStats, error) {
+	var stats ImportStats
+	if err := db.check(true); err != nil {
+		return stats, err
+	}
+
+	var next func() (Record, error)
+	switch format {
+	case FormatJSONL:
+		next = jsonlReader(r)
+	case FormatCSV:
+		var err error
+		if next, err = csvReader(r); err != nil {
+			return stats, err
+		}
+	default:
+		return stats, fmt.Errorf("unknown import format %q", format)
+	}
+
+	batch := make(map[string][]absRecord)
+	count := 0
+	flush := func() error {
+		targets := make([]string, 0, len(batch))
+		for target := range batch {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+
+		for _, target := range targets {
+			if err := db.importTarget(target, batch[target], opts.SkipExisting, &stats); err != nil {
+				return err
+			}
+		}
+		batch = make(map[string][]absRecord)
+		count = 0
+		return nil
+	}
+
+	for {
+		record, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		if record.Type != RecordSlot {
+			stats.Ignored++
+			continue
+		}
+		if record.Target == "" {
+			return stats, fmt.Errorf("slot record %d has no target", stats.Records+1)
+		}
+
+		stats.Records++
+		batch[record.Target] = append(batch[record.Target], absRecord{record.Start, record.Duration})
+		if count++; count >= importBatch {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return stats, err
+	}
+	db.log("imported", zap.Int("added", stats.Added), zap.Int("skipped", stats.Skipped))
+	return stats, nil
+}
+
+// append records to a target, one half-day file at a time
+func (db *TDB) importTarget(target string, records []absRecord, skipExisting bool, stats *ImportStats) error {
+	aliasedHome, unlock, err := db.lockAlias(target, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	files := make(map[fileEncode][]absRecord)
+	var order []fileEncode
+	for _, r := range records {
+		f := encodeFileFromUnix(r.start, db.loc)
+		if _, ok := files[f]; !ok {
+			order = append(order, f)
+		}
+		files[f] = append(files[f], r)
+	}
+	sort.Sort(fileEncodeSlice(order))
+
+	for _, f := range order {
+		if err := db.importFile(aliasedHome, f, files[f], skipExisting, stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *TDB) importFile(aliasedHome string, file fileEncode, records []absRecord, skipExisting bool, stats *ImportStats) error {
+	subFolder, _ := file.path()
+	if err := createFolder(filepath.Join(aliasedHome, subFolder)); err != nil {
+		return err
+	}
+
+	unlock := srcLocker.WriteLock(encodeAliasAndFile(aliasedHome, file))
+	defer unlock()
+
+	baseName := slotBaseName(aliasedHome, file)
+	origin := file.origin(db.loc)
+
+	var seen map[slotRecord]bool
+	if skipExisting {
+		existing, err := db.format.read(baseName)
+		if err = db.reportCorruption(err); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		seen = make(map[slotRecord]bool)
+		for _, r := range existing {
+			seen[r] = true
+		}
+	}
+
+	sortAbsRecords(records)
+	var toAdd []slotRecord
+	for _, r := range records {
+		record := slotRecord{uint16(r.start - origin), r.howlong}
+		if skipExisting {
+			if seen[record] {
+				stats.Skipped++
+				continue
+			}
+			seen[record] = true
+		}
+		toAdd = append(toAdd, record)
+	}
+	if len(toAdd) == 0 {
+		return nil
+	}
+
+	return db.writeRollups(func() error {
+		err := db.format.append(baseName, toAdd, db.opts.Sync)
+		if err = db.reportCorruption(err); err != nil {
+			return err
+		}
+		stats.Added += len(toAdd)
+
+		added := make([]absRecord, len(toAdd))
+		for i, r := range toAdd {
+			added[i] = absRecord{origin + uint32(r.offset), r.howlong}
+		}
+		return db.rollupSlots(aliasedHome, added)
+	})
+}
+
+// a record of JSON Lines, "howlong" names the duration as in CSV
+type jsonlRecord struct {
+	Record
+	Howlong *uint32 `json:"howlong"`
+}
+
+// read the records of JSON Lines, a record without a type is a slot
+func jsonlReader(r io.Reader) func() (Record, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	return func() (Record, error) {
+		var jr jsonlRecord
+		if err := dec.Decode(&jr); err != nil {
+			return jr.Record, err
+		}
+		if jr.Type == "" {
+			jr.Type = RecordSlot
+		}
+		if jr.Howlong != nil && jr.Duration == 0 {
+			jr.Duration = *jr.Howlong
+		}
+		return jr.Record, nil
+	}
+}
+
+// read the records of a CSV, the first row names the columns
+func csvReader(r io.Reader) (func() (Record, error), error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+
+	names, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{"type": -1, "target": -1, "start": -1, "duration": -1}
+	for i, n := range names {
+		if n == "howlong" {
+			n = "duration"
+		}
+		if _, ok := columns[n]; ok {
+			columns[n] = i
+		}
+	}
+	for _, n := range []string{"target", "start", "duration"} {
+		if columns[n] < 0 {
+			return nil, fmt.Errorf("csv has no %q column", n)
+		}
+	}
+
+	line := 0
+	return func() (Record, error) {
+		row, err := cr.Read()
+		if err != nil {
+			return Record{}, err
+		}
+		line++
+
+		get := func(n string) (string, error) {
+			i := columns[n]
+			if i >= len(row) {
+				return "", fmt.Errorf("csv row %d has no %s", line, n)
+			}
+			return row[i], nil
+		}
+		parse := func(n string) (uint32, error) {
+			s, err := get(n)
+			if err != nil {
+				return 0, err
+			}
+			v, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				return 0, fmt.Errorf("csv row %d: %v", line, err)
+			}
+			return uint32(v), nil
+		}
+
+		record := Record{Type: RecordSlot}
+		if columns["type"] >= 0 {
+			if record.Type, err = get("type"); err != nil {
+				return record, err
+			}
+			if record.Type != RecordSlot {
+				return record, nil
+			}
+		}
+		if record.Target, err = get("target"); err != nil {
+			return record, err
+		}
+		if record.Start, err = parse("start"); err != nil {
+			return record, err
+		}
+		record.Duration, err = parse("duration")
+		return record, err
+	}, nil
+}
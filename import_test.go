@@ -0,0 +1,71 @@
+package tdb
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImport(t *testing.T) {
+	src := "test_import_src"
+	dst := "test_import_dst"
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	start := uint32(1491134201)
+	createWithSlots(t, src, map[string][][2]uint32{
+		"a": {{start, 20}, {start + 86400, 30}},
+		"b": {{start + 50, 10}},
+	})
+
+	db, err := OpenWithOptions(src, Options{ReadOnly: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	var jsonl, csv bytes.Buffer
+	assert.NoError(t, db.Export(&jsonl, FormatJSONL, ExportFilter{}), "export wrong")
+	assert.NoError(t, db.Export(&csv, FormatCSV, ExportFilter{}), "export wrong")
+	assert.NoError(t, db.Close(), "close wrong")
+
+	db, err = Open(dst)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stats, err := db.Import(bytes.NewReader(jsonl.Bytes()), FormatJSONL, ImportOptions{})
+	assert.NoError(t, err, "import wrong")
+	assert.Equal(t, ImportStats{Records: 3, Added: 3, Ignored: 1}, stats, "stats wrong")
+	checkSlots(t, db, "a", [][2]uint32{{start, 20}, {start + 86400, 30}})
+	checkSlots(t, db, "b", [][2]uint32{{start + 50, 10}})
+
+	stats, err = db.Import(bytes.NewReader(csv.Bytes()), FormatCSV, ImportOptions{SkipExisting: true})
+	assert.NoError(t, err, "import wrong")
+	assert.Equal(t, ImportStats{Records: 3, Skipped: 3}, stats, "existing should be skipped")
+
+	// columns of another tracker, duplicates are kept without SkipExisting
+	other := "start,howlong,target\n1491134201,20,a\n1491134301,5,a\n"
+	stats, err = db.Import(strings.NewReader(other), FormatCSV, ImportOptions{})
+	assert.NoError(t, err, "import wrong")
+	assert.Equal(t, ImportStats{Records: 2, Added: 2}, stats, "stats wrong")
+	checkSlots(t, db, "a", [][2]uint32{{start, 20}, {start, 20}, {start + 100, 5}, {start + 86400, 30}})
+
+	// a backfill in JSON Lines without types
+	backfill := `{"target":"c","start":1491134201,"howlong":15}` + "\n" + `{"target":"c","start":1491134301,"duration":25}` + "\n"
+	stats, err = db.Import(strings.NewReader(backfill), FormatJSONL, ImportOptions{})
+	assert.NoError(t, err, "import wrong")
+	assert.Equal(t, ImportStats{Records: 2, Added: 2}, stats, "records without type should be slots")
+	checkSlots(t, db, "c", [][2]uint32{{start, 15}, {start + 100, 25}})
+
+	_, err = db.Import(strings.NewReader("target,start\na,1\n"), FormatCSV, ImportOptions{})
+	assert.Error(t, err, "no duration column")
+	_, err = db.Import(strings.NewReader("target,start,duration\na,x,1\n"), FormatCSV, ImportOptions{})
+	assert.Error(t, err, "wrong start")
+	_, err = db.Import(strings.NewReader(`{"type":"slot","start":1}`), FormatJSONL, ImportOptions{})
+	assert.Error(t, err, "no target")
+	_, err = db.Import(strings.NewReader(""), ExportFormat("xml"), ImportOptions{})
+	assert.Error(t, err, "unknown format")
+}
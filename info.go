@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/golang/protobuf/proto"
+	"go.uber.org/zap"
 )
 
 type info struct {
@@ -23,10 +24,16 @@ func createInfo(p string) (*info, error) {
 	one.fileLock = new(sync.RWMutex)
 
 	if stat, err := os.Stat(p); err != nil {
-		if os.IsNotExist(err) {
-			return one, nil
+		if !os.IsNotExist(err) {
+			return one, err
+		}
+		// a crash may happen between the renames of an atomic write
+		if _, err := os.Stat(p + prevExt); err != nil {
+			if os.IsNotExist(err) {
+				return one, nil
+			}
+			return one, err
 		}
-		return one, err
 	} else if stat.IsDir() {
 		return one, ErrPathNotFile
 	}
@@ -37,27 +44,43 @@ func createInfo(p string) (*info, error) {
 }
 
 // loadInfo information from file.
+// Fall back to the previous generation if the current file can't be decoded.
 func (one *info) loadInfo() error {
 	one.contentLock.Lock()
 	defer one.contentLock.Unlock()
 
 	one.fileLock.RLock()
-	b, err := ioutil.ReadFile(one.path)
-	one.fileLock.RUnlock()
+	defer one.fileLock.RUnlock()
+
+	fields, err := readInfoFile(one.path)
 	if err != nil {
-		return err
+		prevFields, prevErr := readInfoFile(one.path + prevExt)
+		if prevErr != nil {
+			return err
+		}
+		p("fall back to previous info", zap.String("path", one.path), zap.Error(err))
+		fields = prevFields
 	}
 
-	var pbInfo Info
-	if err := proto.Unmarshal(b, &pbInfo); err != nil {
-		return err
+	if fields != nil {
+		one.content = fields
 	}
 
-	if pbInfo.Fields != nil {
-		one.content = pbInfo.Fields
+	return nil
+}
+
+// read and decode the fields of an info file
+func readInfoFile(p string) (map[string][]byte, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	var pbInfo Info
+	if err := proto.Unmarshal(b, &pbInfo); err != nil {
+		return nil, err
+	}
+	return pbInfo.Fields, nil
 }
 
 func (one *info) getInfoValue(k string) []byte {
@@ -92,21 +115,39 @@ func (one *info) getAllInfo() ([]string, [][]byte) {
 
 func (one *info) writeToDisk() error {
 	one.contentLock.RLock()
+	defer one.contentLock.RUnlock()
+	return one.writeLocked()
+}
 
+// write content to file, the caller should hold the content lock.
+func (one *info) writeLocked() error {
 	var pbInfo Info
 	pbInfo.Fields = one.content
 
 	b, err := proto.Marshal(&pbInfo)
 	if err != nil {
-		one.contentLock.RUnlock()
 		return err
 	}
-	one.contentLock.RUnlock()
 
 	one.fileLock.Lock()
 	defer one.fileLock.Unlock()
 
-	return ioutil.WriteFile(one.path, b, 0644)
+	return writeFileAtomic(one.path, b)
+}
+
+// delete the given keys from content and write to file
+func (one *info) deleteInfo(k []string) error {
+	if len(k) == 0 {
+		return nil
+	}
+
+	one.contentLock.Lock()
+	for i := 0; i < len(k); i++ {
+		delete(one.content, k[i])
+	}
+	one.contentLock.Unlock()
+
+	return one.writeToDisk()
 }
 
 // update the given keys, values content and write to file
@@ -127,3 +168,19 @@ func (one *info) updateInfo(k []string, v [][]byte) error {
 
 	return one.writeToDisk()
 }
+
+// move the value of key "from" to key "to" and write to file once
+func (one *info) moveInfo(from, to string) error {
+	one.contentLock.Lock()
+	v, ok := one.content[from]
+	if ok {
+		one.content[to] = v
+		delete(one.content, from)
+	}
+	one.contentLock.Unlock()
+
+	if !ok {
+		return ErrNotExist
+	}
+	return one.writeToDisk()
+}
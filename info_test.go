@@ -1,6 +1,7 @@
 package tdb
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -22,6 +23,7 @@ func TestInfoMethods(t *testing.T) {
 
 	demoInfo := "demo_info"
 	defer os.Remove(demoInfo)
+	defer os.Remove(demoInfo + prevExt)
 	one, err := createInfo(demoInfo)
 	assert.NoError(t, err, "should have no error while creating info from non-existed file")
 	assert.Len(t, one.content, 0, "should have empty content")
@@ -66,6 +68,47 @@ func TestInfoMethods(t *testing.T) {
 	assert.EqualValues(t, one.content[key], two.content[key], "the values should be the same")
 }
 
+func TestInfoAtomicWrite(t *testing.T) {
+	demoInfo := "demo_info_atomic"
+	defer os.Remove(demoInfo)
+	defer os.Remove(demoInfo + prevExt)
+
+	one, err := createInfo(demoInfo)
+	assert.NoError(t, err, "should have no error while creating info")
+	assert.NoError(t, one.updateInfo([]string{"a"}, [][]byte{[]byte("1")}), "update wrong")
+	assert.NoError(t, one.updateInfo([]string{"b"}, [][]byte{[]byte("2")}), "update wrong")
+
+	_, err = os.Stat(demoInfo + tmpExt)
+	assert.True(t, os.IsNotExist(err), "temporary file should be renamed")
+
+	// the previous generation is kept
+	prev, err := createInfo(demoInfo + prevExt)
+	assert.NoError(t, err, "should have no error while loading the previous generation")
+	assert.Len(t, prev.content, 1, "previous generation should have one content")
+
+	// a truncated current file falls back to the previous generation
+	b, err := ioutil.ReadFile(demoInfo)
+	assert.NoError(t, err, "read info file wrong")
+	assert.NoError(t, ioutil.WriteFile(demoInfo, b[:len(b)-1], 0644), "truncate info file wrong")
+
+	two, err := createInfo(demoInfo)
+	assert.NoError(t, err, "should fall back to the previous generation")
+	assert.EqualValues(t, []byte("1"), two.getInfoValue("a"), "value wrong")
+	assert.Nil(t, two.getInfoValue("b"), "value should not exist")
+
+	// crash between the renames, only the previous generation exists
+	assert.NoError(t, os.Remove(demoInfo), "remove info file wrong")
+	three, err := createInfo(demoInfo)
+	assert.NoError(t, err, "should load the previous generation")
+	assert.Len(t, three.content, 1, "should have one content")
+
+	// both broken
+	assert.NoError(t, ioutil.WriteFile(demoInfo, []byte{0xff}, 0644), "write info file wrong")
+	assert.NoError(t, ioutil.WriteFile(demoInfo+prevExt, []byte{0xff}, 0644), "write info file wrong")
+	_, err = createInfo(demoInfo)
+	assert.Error(t, err, "should fail when no generation decodes")
+}
+
 func BenchmarkLoad10(b *testing.B) {
 	benchmarkLoad(b, 10)
 }
@@ -85,6 +128,7 @@ func BenchmarkUpdate1000(b *testing.B) {
 func benchmarkLoad(b *testing.B, count int) {
 	benchInfo := "bench_info_load"
 	defer os.Remove(benchInfo)
+	defer os.Remove(benchInfo + prevExt)
 	one, err := createInfo(benchInfo)
 	if err != nil {
 		b.Fatal(err)
@@ -112,6 +156,7 @@ func benchmarkLoad(b *testing.B, count int) {
 func benchmarkUpdate(b *testing.B, count int) {
 	benchInfo := "bench_info_update"
 	defer os.Remove(benchInfo)
+	defer os.Remove(benchInfo + prevExt)
 	one, err := createInfo(benchInfo)
 	if err != nil {
 		b.Fatal(err)
@@ -0,0 +1,140 @@
+package tdb
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"sort"
+)
+
+// Slot is a slot of a target.
+type Slot struct {
+	Target   string `json:"target"`
+	Start    uint32 `json:"start"`
+	Duration uint32 `json:"duration"`
+}
+
+// IterOptions of IterSlots.
+type IterOptions struct {
+	Reverse bool // from the latest slot to the earliest
+	Limit   int  // stop after this many slots, 0 means no limit
+}
+
+// SlotIterator walks the slots of a target one half-day file at a time.
+//
+//	it := db.IterSlots(ctx, target, start, end, IterOptions{})
+//	for it.Next() {
+//		slot := it.Slot()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type SlotIterator struct {
+	db          *TDB
+	ctx         context.Context
+	target      string
+	aliasedHome string
+	start, end  uint32
+	opts        IterOptions
+
+	files []fileEncode // files not read yet, in walking order
+	buf   []Slot       // slots of the current file not returned yet
+	cur   Slot
+	count int
+	err   error
+}
+
+// IterSlots returns an iterator over the slots of a target starting between "start" and "end",
+// 0 "end" means no end. Slots are ordered by start, files are read only when reached.
+func (db *TDB) IterSlots(ctx context.Context, target string, start, end uint32, opts IterOptions) *SlotIterator {
+	it := &SlotIterator{db: db, ctx: ctx, target: target, start: start, end: end, opts: opts}
+	if it.end == 0 {
+		it.end = math.MaxUint32
+	}
+	if it.err = db.check(false); it.err != nil {
+		return it
+	}
+
+	var unlock func()
+	if it.aliasedHome, unlock, it.err = db.lockAlias(target, false); it.err != nil || it.aliasedHome == "" {
+		return it
+	}
+	it.files, it.err = db.getTargetFiles(target, start, end)
+	unlock()
+	if opts.Reverse {
+		for i, j := 0, len(it.files)-1; i < j; i, j = i+1, j-1 {
+			it.files[i], it.files[j] = it.files[j], it.files[i]
+		}
+	}
+	return it
+}
+
+// Next moves to the next slot, false if there is no more or an error happened.
+func (it *SlotIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.opts.Limit > 0 && it.count >= it.opts.Limit {
+		return false
+	}
+
+	for len(it.buf) == 0 {
+		if len(it.files) == 0 {
+			return false
+		}
+		if it.err = it.ctx.Err(); it.err != nil {
+			return false
+		}
+		if it.err = it.db.check(false); it.err != nil {
+			return false
+		}
+		file := it.files[0]
+		it.files = it.files[1:]
+		if it.err = it.read(file); it.err != nil {
+			return false
+		}
+	}
+
+	it.cur = it.buf[0]
+	it.buf = it.buf[1:]
+	it.count++
+	return true
+}
+
+// read the slots in range of a file into the buffer
+func (it *SlotIterator) read(file fileEncode) error {
+	unlock := srcLocker.ReadLock(it.aliasedHome)
+	defer unlock()
+	if b := it.db.slot.getInfoValue(it.target); b == nil || filepath.Base(it.aliasedHome) != string(b) {
+		// the target is deleted
+		it.files = nil
+		return nil
+	}
+
+	starts, slots, err := readSlots(it.db.format, it.aliasedHome, file, it.db.loc)
+	if err = it.db.reportCorruption(err); err != nil {
+		return err
+	}
+
+	for i, s := range starts {
+		if s >= it.start && s <= it.end {
+			it.buf = append(it.buf, Slot{it.target, s, slots[i]})
+		}
+	}
+	sort.SliceStable(it.buf, func(i, j int) bool {
+		if it.opts.Reverse {
+			return it.buf[i].Start > it.buf[j].Start
+		}
+		return it.buf[i].Start < it.buf[j].Start
+	})
+	return nil
+}
+
+// Slot returns the current slot.
+func (it *SlotIterator) Slot() Slot {
+	return it.cur
+}
+
+// Err returns the error stopping the iterator, if any.
+func (it *SlotIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,60 @@
+package tdb
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collect(it *SlotIterator) []uint32 {
+	var starts []uint32
+	for it.Next() {
+		starts = append(starts, it.Slot().Start)
+	}
+	return starts
+}
+
+func TestIterSlots(t *testing.T) {
+	folder := "test_iter"
+	defer os.RemoveAll(folder)
+
+	start := uint32(1491134201)
+	createWithSlots(t, folder, map[string][][2]uint32{
+		"a": {{start + 50, 10}, {start, 20}, {start + 86400, 30}, {start + 2*86400, 40}},
+	})
+
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	it := db.IterSlots(ctx, "a", 0, 0, IterOptions{})
+	assert.Equal(t, []uint32{start, start + 50, start + 86400, start + 2*86400}, collect(it), "order wrong")
+	assert.NoError(t, it.Err(), "iterate wrong")
+	assert.Equal(t, Slot{"a", start + 2*86400, 40}, it.Slot(), "last slot wrong")
+
+	it = db.IterSlots(ctx, "a", start+10, start+86400, IterOptions{Reverse: true})
+	assert.Equal(t, []uint32{start + 86400, start + 50}, collect(it), "reverse wrong")
+
+	it = db.IterSlots(ctx, "a", 0, 0, IterOptions{Limit: 3})
+	assert.Equal(t, []uint32{start, start + 50, start + 86400}, collect(it), "limit wrong")
+	assert.Len(t, it.files, 1, "the last file should not be read")
+
+	assert.Empty(t, collect(db.IterSlots(ctx, "none", 0, 0, IterOptions{})), "no target")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	it = db.IterSlots(cancelled, "a", 0, 0, IterOptions{})
+	assert.True(t, it.Next(), "first slot wrong")
+	assert.True(t, it.Next(), "slot of the same file wrong")
+	cancel()
+	assert.False(t, it.Next(), "should stop")
+	assert.Equal(t, context.Canceled, it.Err(), "error wrong")
+
+	assert.NoError(t, db.Close(), "close wrong")
+	it = db.IterSlots(ctx, "a", 0, 0, IterOptions{})
+	assert.False(t, it.Next(), "closed")
+	assert.Equal(t, ErrClosed, it.Err(), "error wrong")
+}
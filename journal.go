@@ -0,0 +1,173 @@
+package tdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"math"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	journalExt = ".log"
+
+	journalSet    = byte(1)
+	journalDelete = byte(2)
+
+	journalHeaderBytes = 5 // op, key length and value length
+	journalCRCBytes    = 4
+
+	journalLimit = 1024 // records before compaction by default
+)
+
+// journal is an append-only log of changes to an info.
+// It is replayed onto the info snapshot when loading, and reset once the snapshot is rewritten.
+type journal struct {
+	path  string
+	sync  bool // fsync after each append
+	limit int  // records before compaction
+
+	lock  sync.Mutex
+	file  *os.File
+	count int
+}
+
+// openJournal opens the journal of the info and replays it onto the content.
+// A torn record at the tail is truncated unless "readOnly" is true.
+func openJournal(one *info, limit int, sync, readOnly bool) (*journal, error) {
+	if limit <= 0 {
+		limit = journalLimit
+	}
+	j := &journal{path: one.path + journalExt, sync: sync, limit: limit}
+
+	b, err := ioutil.ReadFile(j.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	one.contentLock.Lock()
+	valid := j.replay(b, one.content)
+	one.contentLock.Unlock()
+
+	if readOnly {
+		return j, nil
+	}
+
+	if valid < len(b) {
+		p("truncate torn journal", zap.String("path", j.path), zap.Int("valid", valid), zap.Int("size", len(b)))
+		if err := os.Truncate(j.path, int64(valid)); err != nil {
+			return nil, err
+		}
+	}
+
+	j.file, err = os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// replay records onto content, return the length of the valid bytes.
+func (j *journal) replay(b []byte, content map[string][]byte) int {
+	valid := 0
+	for valid+journalHeaderBytes <= len(b) {
+		op := b[valid]
+		keyLen := int(binary.LittleEndian.Uint16(b[valid+1:]))
+		valueLen := int(binary.LittleEndian.Uint16(b[valid+3:]))
+
+		end := valid + journalHeaderBytes + keyLen + valueLen
+		if end+journalCRCBytes > len(b) {
+			break
+		}
+		if crc32.ChecksumIEEE(b[valid:end]) != binary.LittleEndian.Uint32(b[end:]) {
+			break
+		}
+
+		key := string(b[valid+journalHeaderBytes : valid+journalHeaderBytes+keyLen])
+		switch op {
+		case journalSet:
+			value := make([]byte, valueLen)
+			copy(value, b[valid+journalHeaderBytes+keyLen:end])
+			content[key] = value
+		case journalDelete:
+			delete(content, key)
+		default:
+			return valid
+		}
+
+		valid = end + journalCRCBytes
+		j.count++
+	}
+	return valid
+}
+
+// append a record to the journal.
+func (j *journal) append(op byte, key string, value []byte) error {
+	if len(key) > math.MaxUint16 || len(value) > math.MaxUint16 {
+		return errors.New("journal record is too large")
+	}
+
+	b := make([]byte, journalHeaderBytes+len(key)+len(value)+journalCRCBytes)
+	b[0] = op
+	binary.LittleEndian.PutUint16(b[1:], uint16(len(key)))
+	binary.LittleEndian.PutUint16(b[3:], uint16(len(value)))
+	copy(b[journalHeaderBytes:], key)
+	copy(b[journalHeaderBytes+len(key):], value)
+
+	end := len(b) - journalCRCBytes
+	binary.LittleEndian.PutUint32(b[end:], crc32.ChecksumIEEE(b[:end]))
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.file == nil {
+		return ErrClosed
+	}
+	if _, err := j.file.Write(b); err != nil {
+		return err
+	}
+	j.count++
+	if j.sync {
+		return j.file.Sync()
+	}
+	return nil
+}
+
+// full reports whether the journal reached the compaction limit.
+func (j *journal) full() bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.count >= j.limit
+}
+
+// reset empties the journal after the snapshot is written.
+func (j *journal) reset() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.file == nil {
+		return ErrClosed
+	}
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	j.count = 0
+	return nil
+}
+
+// close the journal file.
+func (j *journal) close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
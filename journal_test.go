@@ -0,0 +1,109 @@
+package tdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal(t *testing.T) {
+	demoInfo := "demo_journal"
+	defer os.Remove(demoInfo + journalExt)
+
+	one, err := createInfo(demoInfo)
+	assert.NoError(t, err, "should have no error while creating info")
+	j, err := openJournal(one, 0, false, false)
+	if !assert.NoError(t, err, "should have no error while opening journal") {
+		t.Fatal(err)
+	}
+	assert.Equal(t, journalLimit, j.limit, "default limit wrong")
+
+	assert.NoError(t, j.append(journalSet, "a", []byte("1")), "append wrong")
+	assert.NoError(t, j.append(journalSet, "b", []byte("2")), "append wrong")
+	assert.NoError(t, j.append(journalSet, "a", []byte("3")), "append wrong")
+	assert.NoError(t, j.append(journalDelete, "b", nil), "append wrong")
+	assert.NoError(t, j.close(), "close wrong")
+	assert.Equal(t, ErrClosed, j.append(journalSet, "c", nil), "append after close")
+
+	// torn tail is ignored and truncated
+	b, err := ioutil.ReadFile(demoInfo + journalExt)
+	assert.NoError(t, err, "read journal wrong")
+	valid := len(b)
+	assert.NoError(t, ioutil.WriteFile(demoInfo+journalExt, append(b, journalSet, 1, 0), 0644), "write journal wrong")
+
+	two, err := createInfo(demoInfo)
+	assert.NoError(t, err, "should have no error while creating info")
+	j, err = openJournal(two, 3, false, false)
+	if !assert.NoError(t, err, "should have no error while replaying journal") {
+		t.Fatal(err)
+	}
+	defer j.close()
+	assert.Equal(t, map[string][]byte{"a": []byte("3")}, two.content, "replayed content wrong")
+	assert.True(t, j.full(), "journal should reach the limit")
+
+	stat, err := os.Stat(demoInfo + journalExt)
+	assert.NoError(t, err, "stat journal wrong")
+	assert.Equal(t, int64(valid), stat.Size(), "torn record should be truncated")
+
+	assert.NoError(t, j.reset(), "reset wrong")
+	assert.False(t, j.full(), "journal should be empty")
+}
+
+func TestActionJournal(t *testing.T) {
+	folder := "test_action_journal"
+	defer os.RemoveAll(folder)
+
+	db, err := OpenWithOptions(folder, Options{ActionLogLimit: 10})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+
+	target := string(randBytes(6))
+	now := uint32(time.Now().Unix())
+	for i := uint32(0); i < 5; i++ {
+		assert.NoError(t, db.AddAction(target, now+i), "error add an action")
+	}
+
+	// heartbeats only go to the journal
+	_, err = os.Stat(filepath.Join(folder, actionIndex))
+	assert.True(t, os.IsNotExist(err), "snapshot should not be written")
+
+	// replayed on open
+	db2, err := OpenWithOptions(folder, Options{ReadOnly: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	_, starts, lasts, err := db2.GetActions()
+	assert.NoError(t, err, "get all actions wrong")
+	assert.Equal(t, []uint32{now}, starts, "start wrong")
+	assert.Equal(t, []uint32{now + 4}, lasts, "last wrong")
+
+	// compacted when the limit is reached
+	for i := uint32(5); i < 10; i++ {
+		assert.NoError(t, db.AddAction(target, now+i), "error add an action")
+	}
+	_, err = os.Stat(filepath.Join(folder, actionIndex))
+	assert.NoError(t, err, "snapshot should be written")
+	assert.False(t, db.actionLog.full(), "journal should be reset")
+
+	// compacted on close
+	assert.NoError(t, db.AddAction(target, now+10), "error add an action")
+	assert.NoError(t, db.Close(), "error closing")
+	stat, err := os.Stat(filepath.Join(folder, actionIndex+journalExt))
+	assert.NoError(t, err, "stat journal wrong")
+	assert.Equal(t, int64(0), stat.Size(), "journal should be empty after close")
+
+	db, err = Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, starts, lasts, err = db.GetActions()
+	assert.NoError(t, err, "get all actions wrong")
+	assert.Equal(t, []uint32{now}, starts, "start wrong")
+	assert.Equal(t, []uint32{now + 10}, lasts, "last wrong")
+}
@@ -0,0 +1,343 @@
+package tdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// merged sources by tag, holds the unix time of the last merge for MergedFrom
+	mergedPrefix = "merged:"
+)
+
+// ErrSameDatabase the database to merge has the same tag
+var ErrSameDatabase = errors.New("can't merge a database into itself")
+
+// MergePolicy decides how slots overlapping the existing ones are merged.
+type MergePolicy uint8
+
+// Policies of Merge, identical slots are always merged once.
+const (
+	// MergeKeepBoth keeps the overlapping slots of both databases.
+	MergeKeepBoth MergePolicy = iota
+	// MergeSkipOverlap drops the merged slots overlapping existing ones.
+	MergeSkipOverlap
+	// MergeUnion joins overlapping slots into one covering both.
+	MergeUnion
+)
+
+// MergeOptions of Merge.
+type MergeOptions struct {
+	Policy MergePolicy
+}
+
+// MergeStats of Merge.
+type MergeStats struct {
+	Targets    int // targets in the merged database
+	Added      int // slots added as they are
+	Duplicates int // identical slots skipped
+	Overlaps   int // overlapping slots skipped or joined
+}
+
+// Merge imports all targets and slots of the database at "srcPath" into "dst".
+// Aliases are remapped to the ones of "dst" and slots are bucketed in its zone.
+// The tag of the merged database is recorded with the time of the merge, see MergedFrom.
+// Identical slots are always merged once, so merging an unchanged database again adds nothing,
+// but slots changed in it since are added again unless the policy is MergeSkipOverlap.
+// Open actions are not merged.
+func Merge(dst *TDB, srcPath string, opts MergeOptions) (MergeStats, error) {
+	var stats MergeStats
+	if err := dst.check(true); err != nil {
+		return stats, err
+	}
+
+	src, err := OpenWithOptions(srcPath, Options{ReadOnly: true, Logger: dst.opts.Logger})
+	if err != nil {
+		return stats, err
+	}
+	defer src.Close()
+
+	srcTag, err := src.Tag()
+	if err != nil {
+		return stats, err
+	}
+	dstTag, err := dst.Tag()
+	if err != nil {
+		return stats, err
+	}
+	if srcTag == dstTag {
+		return stats, ErrSameDatabase
+	}
+
+	targets := src.GetTargets()
+	sort.Strings(targets)
+	for _, target := range targets {
+		starts, slots, err := src.GetSlots(target, 0, 0)
+		if err != nil {
+			return stats, err
+		}
+
+		var records []absRecord
+		for i := range starts {
+			for j := range starts[i] {
+				records = append(records, absRecord{starts[i][j], slots[i][j]})
+			}
+		}
+		err = dst.writeRollups(func() error {
+			return dst.mergeTarget(target, records, opts.Policy, &stats)
+		})
+		if err != nil {
+			return stats, err
+		}
+		stats.Targets++
+	}
+
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(dst.clock.Now().Unix()))
+	dst.log("merged", zap.String("tag", srcTag), zap.Int("targets", stats.Targets), zap.Int("added", stats.Added))
+	return stats, dst.meta.updateInfo([]string{mergedPrefix + srcTag}, [][]byte{b})
+}
+
+// MergedFrom returns the tags of the merged databases with the unix time of their last merge.
+func (db *TDB) MergedFrom() map[string]uint32 {
+	merged := make(map[string]uint32)
+	keys, values := db.meta.getAllInfo()
+	for i, k := range keys {
+		if strings.HasPrefix(k, mergedPrefix) && len(values[i]) == 4 {
+			merged[strings.TrimPrefix(k, mergedPrefix)] = binary.LittleEndian.Uint32(values[i])
+		}
+	}
+	return merged
+}
+
+// absRecord is a slot with its unix time start.
+type absRecord struct {
+	start   uint32
+	howlong uint32
+}
+
+func (r absRecord) end() uint64 {
+	return uint64(r.start) + uint64(r.howlong)
+}
+
+func (r absRecord) overlaps(o absRecord) bool {
+	return uint64(r.start) < o.end() && uint64(o.start) < r.end()
+}
+
+func sortAbsRecords(records []absRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].start != records[j].start {
+			return records[i].start < records[j].start
+		}
+		return records[i].howlong < records[j].howlong
+	})
+}
+
+// merge records into a target, the half-day files of the target are locked meanwhile.
+func (db *TDB) mergeTarget(target string, records []absRecord, policy MergePolicy, stats *MergeStats) error {
+	aliasedHome, unlock, err := db.lockAlias(target, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	existFiles, err := db.getTargetFiles(target, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	// lock every file read or written, in order
+	lockFiles := make(map[fileEncode]bool)
+	for _, f := range existFiles {
+		lockFiles[f] = true
+	}
+	for _, r := range records {
+		lockFiles[encodeFileFromUnix(r.start, db.loc)] = true
+	}
+	var sorted []fileEncode
+	for f := range lockFiles {
+		sorted = append(sorted, f)
+	}
+	sort.Sort(fileEncodeSlice(sorted))
+	for _, f := range sorted {
+		unlock := srcLocker.WriteLock(encodeAliasAndFile(aliasedHome, f))
+		defer unlock()
+	}
+
+	// existing records by file
+	existing := make(map[fileEncode][]slotRecord)
+	var all []absRecord
+	for _, f := range existFiles {
+		rs, err := db.format.read(slotBaseName(aliasedHome, f))
+		if err = db.reportCorruption(err); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		existing[f] = rs
+		origin := f.origin(db.loc)
+		for _, r := range rs {
+			all = append(all, absRecord{origin + uint32(r.offset), r.howlong})
+		}
+	}
+	sortAbsRecords(all)
+	index := newOverlapIndex(all)
+
+	seen := make(map[absRecord]bool)
+	for _, r := range all {
+		seen[r] = true
+	}
+
+	var added []absRecord
+	for _, r := range records {
+		if seen[r] {
+			stats.Duplicates++
+			continue
+		}
+		seen[r] = true
+
+		if policy != MergeKeepBoth && index.overlaps(r) {
+			stats.Overlaps++
+			if policy == MergeSkipOverlap {
+				continue
+			}
+		} else {
+			stats.Added++
+		}
+		added = append(added, r)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	if policy != MergeUnion {
+		return db.appendAbsRecords(aliasedHome, added)
+	}
+
+	// rewrite the files changed by the union
+	union := unionRecords(append(all, added...))
+	files := make(map[fileEncode][]slotRecord)
+	for _, r := range union {
+		f := encodeFileFromUnix(r.start, db.loc)
+		files[f] = append(files[f], slotRecord{uint16(r.start - f.origin(db.loc)), r.howlong})
+	}
+	var oldRecords, newRecords []absRecord
+	for _, f := range sorted {
+		if sameRecords(existing[f], files[f]) {
+			continue
+		}
+		origin := f.origin(db.loc)
+		for _, r := range existing[f] {
+			oldRecords = append(oldRecords, absRecord{origin + uint32(r.offset), r.howlong})
+		}
+		for _, r := range files[f] {
+			newRecords = append(newRecords, absRecord{origin + uint32(r.offset), r.howlong})
+		}
+
+		if len(files[f]) == 0 {
+			// all joined into a slot of an earlier file
+			if err := removeExts(slotBaseName(aliasedHome, f), db.format.exts()); err != nil {
+				return err
+			}
+			continue
+		}
+		subFolder, _ := f.path()
+		if err := createFolder(filepath.Join(aliasedHome, subFolder)); err != nil {
+			return err
+		}
+		if err := db.format.write(slotBaseName(aliasedHome, f), files[f]); err != nil {
+			return err
+		}
+	}
+	return db.rerollSlots(aliasedHome, oldRecords, newRecords)
+}
+
+// append records to their half-day files, the files are locked by the caller
+func (db *TDB) appendAbsRecords(aliasedHome string, records []absRecord) error {
+	sortAbsRecords(records)
+	files := make(map[fileEncode][]slotRecord)
+	var order []fileEncode
+	for _, r := range records {
+		f := encodeFileFromUnix(r.start, db.loc)
+		if _, ok := files[f]; !ok {
+			order = append(order, f)
+		}
+		files[f] = append(files[f], slotRecord{uint16(r.start - f.origin(db.loc)), r.howlong})
+	}
+
+	for _, f := range order {
+		subFolder, _ := f.path()
+		if err := createFolder(filepath.Join(aliasedHome, subFolder)); err != nil {
+			return err
+		}
+		err := db.format.append(slotBaseName(aliasedHome, f), files[f], db.opts.Sync)
+		if err = db.reportCorruption(err); err != nil {
+			return err
+		}
+	}
+	return db.rollupSlots(aliasedHome, records)
+}
+
+// overlapIndex finds whether a record overlaps any of the records sorted by start.
+type overlapIndex struct {
+	sorted []absRecord
+	maxEnd []uint64 // the latest end of sorted[:i+1]
+}
+
+func newOverlapIndex(sorted []absRecord) *overlapIndex {
+	oi := &overlapIndex{sorted: sorted, maxEnd: make([]uint64, len(sorted))}
+	var maxEnd uint64
+	for i, r := range sorted {
+		if r.end() > maxEnd {
+			maxEnd = r.end()
+		}
+		oi.maxEnd[i] = maxEnd
+	}
+	return oi
+}
+
+// whether r overlaps any of the records
+func (oi *overlapIndex) overlaps(r absRecord) bool {
+	// only the records starting before the end of r can overlap it
+	n := sort.Search(len(oi.sorted), func(i int) bool { return uint64(oi.sorted[i].start) >= r.end() })
+	return n > 0 && oi.maxEnd[n-1] > uint64(r.start)
+}
+
+// join overlapping records into one covering them, the result is sorted
+func unionRecords(records []absRecord) []absRecord {
+	sortAbsRecords(records)
+
+	var union []absRecord
+	for _, r := range records {
+		if l := len(union) - 1; l >= 0 && union[l].overlaps(r) {
+			if r.end() > union[l].end() {
+				union[l].howlong = uint32(r.end() - uint64(union[l].start))
+			}
+			continue
+		}
+		union = append(union, r)
+	}
+	return union
+}
+
+// whether two files have the same records in any order
+func sameRecords(a, b []slotRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[slotRecord]int)
+	for _, r := range a {
+		count[r]++
+	}
+	for _, r := range b {
+		if count[r] == 0 {
+			return false
+		}
+		count[r]--
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package tdb
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// create a database with the slots of targets
+func createWithSlots(t *testing.T, folder string, slots map[string][][2]uint32) {
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	for target, ss := range slots {
+		for _, s := range ss {
+			assert.NoError(t, db.AddSlot(target, s[0], s[1]), "add slot wrong")
+		}
+	}
+	assert.NoError(t, db.Close(), "close wrong")
+}
+
+func checkSlots(t *testing.T, db *TDB, target string, expected [][2]uint32) {
+	starts, slots, err := db.GetSlots(target, 0, 0)
+	assert.NoError(t, err, "get slots wrong")
+
+	var got [][2]uint32
+	for i := range starts {
+		for j := range starts[i] {
+			got = append(got, [2]uint32{starts[i][j], slots[i][j]})
+		}
+	}
+	assert.Equal(t, expected, got, "slots of %s wrong", target)
+}
+
+func TestMerge(t *testing.T) {
+	src := "test_merge_src"
+	defer os.RemoveAll(src)
+
+	start := uint32(1491134201)
+	createWithSlots(t, src, map[string][][2]uint32{
+		"x": {{start, 20}, {start + 10, 20}, {start + 100, 5}},
+		"y": {{start, 30}},
+	})
+
+	tests := []struct {
+		policy   MergePolicy
+		stats    MergeStats
+		expected [][2]uint32
+	}{
+		{MergeKeepBoth, MergeStats{2, 3, 1, 0}, [][2]uint32{{start, 20}, {start + 10, 20}, {start + 100, 5}}},
+		{MergeSkipOverlap, MergeStats{2, 2, 1, 1}, [][2]uint32{{start, 20}, {start + 100, 5}}},
+		{MergeUnion, MergeStats{2, 2, 1, 1}, [][2]uint32{{start, 30}, {start + 100, 5}}},
+	}
+
+	for _, tt := range tests {
+		dst := "test_merge_dst"
+		createWithSlots(t, dst, map[string][][2]uint32{"x": {{start, 20}}})
+
+		db, err := Open(dst)
+		if !assert.NoError(t, err, "should have no error") {
+			t.Fatal(err)
+		}
+
+		stats, err := Merge(db, src, MergeOptions{Policy: tt.policy})
+		assert.NoError(t, err, "merge wrong")
+		assert.Equal(t, tt.stats, stats, "stats of policy %d wrong", tt.policy)
+		checkSlots(t, db, "x", tt.expected)
+		checkSlots(t, db, "y", [][2]uint32{{start, 30}})
+
+		// merging again changes nothing
+		_, err = Merge(db, src, MergeOptions{Policy: tt.policy})
+		assert.NoError(t, err, "merge wrong")
+		checkSlots(t, db, "x", tt.expected)
+		checkSlots(t, db, "y", [][2]uint32{{start, 30}})
+
+		srcDB, err := OpenWithOptions(src, Options{ReadOnly: true})
+		assert.NoError(t, err, "open wrong")
+		tag, err := srcDB.Tag()
+		assert.NoError(t, err, "tag wrong")
+		assert.NoError(t, srcDB.Close(), "close wrong")
+		assert.Contains(t, db.MergedFrom(), tag, "tag should be recorded")
+
+		_, err = Merge(db, dst, MergeOptions{})
+		assert.Equal(t, ErrSameDatabase, err, "merge into itself")
+
+		assert.NoError(t, db.Close(), "close wrong")
+		_, err = Merge(db, src, MergeOptions{})
+		assert.Equal(t, ErrClosed, err, "merge into closed")
+		os.RemoveAll(dst)
+	}
+}
+
+func TestUnionRecords(t *testing.T) {
+	union := unionRecords([]absRecord{{100, 10}, {0, 50}, {40, 20}, {60, 0}, {105, 2}})
+	assert.Equal(t, []absRecord{{0, 60}, {60, 0}, {100, 10}}, union, "union wrong")
+
+	index := newOverlapIndex(union)
+	assert.True(t, index.overlaps(absRecord{50, 20}), "should overlap")
+	assert.False(t, index.overlaps(absRecord{60, 40}), "should not overlap")
+	assert.False(t, index.overlaps(absRecord{0, 0}), "empty record at a start")
+
+	// a long record earlier still overlaps past the shorter ones after it
+	index = newOverlapIndex([]absRecord{{0, 100}, {10, 5}, {20, 5}})
+	assert.True(t, index.overlaps(absRecord{50, 10}), "should overlap the long record")
+	assert.False(t, index.overlaps(absRecord{100, 10}), "should not overlap")
+}
@@ -16,7 +16,8 @@ import (
 const (
 	metafile = "__metadata__"
 
-	version = uint8(1)
+	version    = uint8(2) // the latest version, used for new databases
+	minVersion = uint8(1) // the oldest version still readable
 
 	versionKey    = "version"
 	tagKey        = "tag"
@@ -25,30 +26,70 @@ const (
 	usernameKey   = "username"
 	osKey         = "os"
 	zoneOffsetKey = "zoneoffset"
+
+	actionExpKey        = "actionexp"
+	actionExpRulePrefix = "actionexp:"
 )
 
 func (db *TDB) loadMeta() error {
+	for _, marker := range []string{migrateMarker, rebucketMarker} {
+		if _, err := os.Stat(path.Join(db.path, marker)); err == nil {
+			return ErrMigrating
+		}
+	}
+
 	var err error
 	db.meta, err = createInfo(path.Join(db.path, metafile))
 	if err != nil {
 		return err
 	}
-	if len(db.meta.content) == 0 {
-		return db.meta.generateMeta()
+	if len(db.meta.content) == 0 && !db.opts.ReadOnly {
+		v := db.opts.Version
+		if v == 0 {
+			v = version
+		}
+		if v < minVersion || v > version {
+			return ErrVersion
+		}
+		if err := db.meta.generateMeta(db.clock.Now(), v, db.opts.Zone); err != nil {
+			return err
+		}
+	}
+
+	// the slot format follows the version
+	v, err := db.Version()
+	if err != nil {
+		return err
+	}
+	if db.format, err = formatOf(v); err != nil {
+		return err
+	}
+
+	// slot files are bucketed in the zone
+	zone, err := db.Zone()
+	if err != nil {
+		return err
+	}
+	if db.loc, err = zoneLocation(db.meta, zone); err != nil {
+		return err
 	}
+
+	// days of rollups and aggregates follow the zone offset
+	db.fixedLoc, err = zoneLocation(db.meta, ZoneFixed)
 	return err
 }
 
-func (meta *info) generateMeta() error {
+// generate metadata for a new database of version "v" created at "now",
+// slot files are bucketed in "zone".
+func (meta *info) generateMeta(now time.Time, v uint8, zone Zone) error {
 	var keys []string
 	var values [][]byte
 	var err error
 
 	// version
 	keys = append(keys, versionKey)
-	values = append(values, []byte{byte(version)})
+	values = append(values, []byte{byte(v)})
 
-	now := time.Now()
 	// tag
 	var id ulid.ULID
 	if id, err = ulid.NewFromTime(now); err != nil {
@@ -90,6 +131,17 @@ func (meta *info) generateMeta() error {
 	keys = append(keys, zoneOffsetKey)
 	values = append(values, buf.Bytes())
 
+	// zone
+	if int(zone) >= len(zoneNames) {
+		return fmt.Errorf("unknown zone %d", zone)
+	}
+	keys = append(keys, zoneKey)
+	values = append(values, []byte{byte(zone)})
+
+	// rollups of a new database are complete
+	keys = append(keys, rollupsKey)
+	values = append(values, []byte{1})
+
 	return meta.updateInfo(keys, values)
 }
 
@@ -98,7 +150,7 @@ func (db *TDB) getMeta(k string) ([]byte, error) {
 		return nil, ErrNotExist
 	}
 
-	b := db.meta.content[k]
+	b := db.meta.getInfoValue(k)
 	if b == nil {
 		return nil, ErrNotExist
 	}
@@ -107,9 +159,17 @@ func (db *TDB) getMeta(k string) ([]byte, error) {
 
 // Version for tdb.
 func (db *TDB) Version() (uint8, error) {
-	b, err := db.getMeta(versionKey)
-	if err != nil {
-		return 0, err
+	if db.meta == nil {
+		return 0, ErrNotExist
+	}
+	return metaVersion(db.meta)
+}
+
+// get the version in the metadata
+func metaVersion(meta *info) (uint8, error) {
+	b := meta.getInfoValue(versionKey)
+	if b == nil {
+		return 0, ErrNotExist
 	}
 
 	if len(b) != 1 {
@@ -185,10 +245,64 @@ func (db *TDB) ZoneOffset() (int32, error) {
 	if err != nil {
 		return 0, err
 	}
+	return decodeZoneOffset(b)
+}
+
+func decodeZoneOffset(b []byte) (int32, error) {
 	buf := new(bytes.Buffer)
 	buf.Write(b)
 
 	var offset int32
-	err = binary.Read(buf, binary.LittleEndian, &offset)
+	err := binary.Read(buf, binary.LittleEndian, &offset)
 	return offset, err
 }
+
+// Metadata of a database, see the getters of each field.
+type Metadata struct {
+	Version    uint8  `json:"version"`
+	Tag        string `json:"tag"`
+	CreateAt   uint32 `json:"create_at"`
+	Host       string `json:"host"`
+	Arch       string `json:"arch"`
+	Username   string `json:"username"`
+	OS         string `json:"os"`
+	ZoneOffset int32  `json:"zone_offset"`
+	Zone       string `json:"zone"`
+}
+
+// Metadata gets all metadata of the database.
+func (db *TDB) Metadata() (Metadata, error) {
+	var m Metadata
+	var err error
+	if m.Version, err = db.Version(); err != nil {
+		return m, err
+	}
+	if m.Tag, err = db.Tag(); err != nil {
+		return m, err
+	}
+	if m.CreateAt, err = db.CreateAt(); err != nil {
+		return m, err
+	}
+	if m.Host, err = db.Host(); err != nil {
+		return m, err
+	}
+	if m.Arch, err = db.Arch(); err != nil {
+		return m, err
+	}
+	if m.Username, err = db.Username(); err != nil {
+		return m, err
+	}
+	if m.OS, err = db.OS(); err != nil {
+		return m, err
+	}
+	if m.ZoneOffset, err = db.ZoneOffset(); err != nil {
+		return m, err
+	}
+
+	zone, err := db.Zone()
+	if err != nil {
+		return m, err
+	}
+	m.Zone = zone.String()
+	return m, nil
+}
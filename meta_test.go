@@ -68,4 +68,48 @@ func TestMetadata(t *testing.T) {
 	offset, err := db.ZoneOffset()
 	assert.NoError(t, err, "should have no error to get zone offset")
 	assert.Equal(t, int32(thisOffset), offset, "offset value is wrong")
+
+	// test all at once
+	m, err := db.Metadata()
+	assert.NoError(t, err, "should have no error to get metadata")
+	assert.Equal(t, Metadata{
+		Version:    v,
+		Tag:        tag,
+		CreateAt:   createAt,
+		Host:       host,
+		Arch:       arch,
+		Username:   username,
+		OS:         os,
+		ZoneOffset: offset,
+		Zone:       "local",
+	}, m, "metadata wrong")
+}
+
+func TestMetadataConcurrentUpdate(t *testing.T) {
+	folder := "test_meta_concurrent"
+	defer os.RemoveAll(folder)
+
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// metadata is updated at runtime by Merge and the rollups, readers must not race them
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			assert.NoError(t, db.meta.updateInfo([]string{mergedPrefix + "x"}, [][]byte{{byte(i), 0, 0, 0}}), "update wrong")
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		_, err := db.Metadata()
+		assert.NoError(t, err, "metadata wrong")
+	}
 }
@@ -0,0 +1,229 @@
+package tdb
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// marker of an unfinished migration, holds the from and to versions
+	migrateMarker = "__migrate__"
+)
+
+// Migrate converts the database folder in place to version "to", 0 means the latest version.
+// The folder is copied to "<folder>.v<from>.bak" first. If a migration is interrupted,
+// calling Migrate again with the same version resumes it.
+// The database should not be opened while migrating.
+func Migrate(folder string, to uint8) error {
+	if to == 0 {
+		to = version
+	}
+	toFormat, err := formatOf(to)
+	if err != nil {
+		return err
+	}
+
+	from, resume, err := migrationState(folder, to)
+	if err != nil {
+		return err
+	}
+	if from == to && !resume {
+		return nil
+	}
+	fromFormat, err := formatOf(from)
+	if err != nil {
+		return err
+	}
+
+	markerPath := filepath.Join(folder, migrateMarker)
+	if !resume {
+		backup, err := backupPath(folder, fmt.Sprintf("v%d", from))
+		if err != nil {
+			return err
+		}
+		p("backup before migrating", zap.String("path", folder), zap.String("backup", backup))
+		// a backup without marker is left by an interrupted run, redo it
+		if err := os.RemoveAll(backup); err != nil {
+			return err
+		}
+		if err := copyFolder(folder, backup); err != nil {
+			return err
+		}
+		if err := writeFile(markerPath, []byte{from, to}, true); err != nil {
+			return err
+		}
+	}
+
+	if from != to {
+		if err := convertSlots(folder, fromFormat, toFormat); err != nil {
+			return err
+		}
+	}
+	if err := compactInfos(folder); err != nil {
+		return err
+	}
+
+	meta, err := createInfo(filepath.Join(folder, metafile))
+	if err != nil {
+		return err
+	}
+	if err := meta.updateInfo([]string{versionKey}, [][]byte{{to}}); err != nil {
+		return err
+	}
+	return os.Remove(markerPath)
+}
+
+// get the current version of the database and whether a migration to "to" is unfinished
+func migrationState(folder string, to uint8) (uint8, bool, error) {
+	b, err := ioutil.ReadFile(filepath.Join(folder, migrateMarker))
+	if err == nil {
+		if len(b) != 2 {
+			return 0, false, fmt.Errorf("wrong migration marker, byte length should be 2, but is %d", len(b))
+		}
+		if b[1] != to {
+			return 0, false, fmt.Errorf("an unfinished migration is to version %d", b[1])
+		}
+		return b[0], true, nil
+	}
+	if !os.IsNotExist(err) {
+		return 0, false, err
+	}
+
+	meta, err := createInfo(filepath.Join(folder, metafile))
+	if err != nil {
+		return 0, false, err
+	}
+	v, err := metaVersion(meta)
+	if err == ErrNotExist {
+		// nothing created yet
+		return to, false, nil
+	}
+	return v, false, err
+}
+
+// convert every half-day file still in the "from" format to the "to" format.
+// The source files are removed only after the converted one is written, so it can be rerun.
+func convertSlots(folder string, from, to slotFormat) error {
+	slotsHome := filepath.Join(folder, slotsFolder)
+	aliases, err := readFolderNames(slotsHome)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, alias := range aliases {
+		aliasedHome := filepath.Join(slotsHome, alias)
+		if _, err := checkFolderExist(aliasedHome); err == ErrPathNotFolder {
+			continue
+		}
+
+		err := walkSlotFiles(aliasedHome, from.exts(), func(subFolder, fileName string) error {
+			baseName := filepath.Join(aliasedHome, subFolder, fileName)
+
+			records, err := from.read(baseName)
+			if cerr, ok := err.(*CorruptionError); ok {
+				p("migrate consistent records only", zap.Error(cerr))
+			} else if os.IsNotExist(err) {
+				// only a part of a v1 pair is left
+				records = nil
+			} else if err != nil {
+				return err
+			}
+
+			if err := to.write(baseName, records); err != nil {
+				return err
+			}
+			return removeExts(baseName, from.exts())
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrite the info files, the action journal is compacted into the snapshot
+func compactInfos(folder string) error {
+	for _, name := range []string{metafile, slotIndex} {
+		one, err := createInfo(filepath.Join(folder, name))
+		if err != nil {
+			return err
+		}
+		if err := one.writeToDisk(); err != nil {
+			return err
+		}
+	}
+
+	action, err := createInfo(filepath.Join(folder, actionIndex))
+	if err != nil {
+		return err
+	}
+	j, err := openJournal(action, 0, false, false)
+	if err != nil {
+		return err
+	}
+	defer j.close()
+
+	if err := action.writeToDisk(); err != nil {
+		return err
+	}
+	return j.reset()
+}
+
+// remove the files of a half-day with the exts
+func removeExts(baseName string, exts []string) error {
+	for _, ext := range exts {
+		if err := os.Remove(baseName + ext); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// copy a folder recursively
+func copyFolder(src, dst string) error {
+	return filepath.Walk(src, func(path string, stat os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if stat.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
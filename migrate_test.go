@@ -0,0 +1,132 @@
+package tdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// create a v1 database with two slots and an action
+func createV1(t *testing.T, folder string) uint32 {
+	db, err := OpenWithOptions(folder, Options{Version: 1})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+
+	start := uint32(1491134201)
+	assert.NoError(t, db.AddSlot("a", start, 20), "add slot wrong")
+	assert.NoError(t, db.AddSlot("a", start+86400, 30), "add slot wrong")
+	assert.NoError(t, db.AddAction("b", uint32(time.Now().Unix())), "add action wrong")
+	assert.NoError(t, db.Close(), "close wrong")
+	return start
+}
+
+func checkMigrated(t *testing.T, folder string, v uint8, start uint32) {
+	db, err := OpenWithOptions(folder, Options{ReadOnly: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	thisVersion, err := db.Version()
+	assert.NoError(t, err, "version wrong")
+	assert.Equal(t, v, thisVersion, "version should be bumped")
+	assert.Equal(t, v, db.format.version(), "format wrong")
+
+	starts, slots, err := db.GetSlots("a", 0, 0)
+	assert.NoError(t, err, "get slots wrong")
+	assert.Equal(t, [][]uint32{{start}, {start + 86400}}, starts, "starts wrong")
+	assert.Equal(t, [][]uint32{{20}, {30}}, slots, "slots wrong")
+
+	targets, _, _, err := db.GetActions()
+	assert.NoError(t, err, "get actions wrong")
+	assert.Equal(t, []string{"b"}, targets, "actions wrong")
+
+	report, err := db.Verify(context.Background())
+	assert.NoError(t, err, "verify wrong")
+	assert.True(t, report.OK(), "should have no problem: %v", report.Problems)
+	assert.Equal(t, 2, report.Files, "files wrong")
+}
+
+func TestMigrate(t *testing.T) {
+	folder := "test_migrate"
+	backup := folder + ".v1.bak"
+	defer os.RemoveAll(folder)
+	defer os.RemoveAll(backup)
+
+	start := createV1(t, folder)
+
+	// v1 is still readable
+	checkMigrated(t, folder, 1, start)
+
+	assert.NoError(t, Migrate(folder, 0), "migrate wrong")
+	checkMigrated(t, folder, 2, start)
+
+	_, err := os.Stat(filepath.Join(folder, migrateMarker))
+	assert.True(t, os.IsNotExist(err), "marker should be removed")
+	checkMigrated(t, backup, 1, start)
+
+	// nothing to do
+	assert.NoError(t, Migrate(folder, 2), "migrate wrong")
+
+	// back to v1
+	assert.NoError(t, Migrate(folder, 1), "migrate wrong")
+	checkMigrated(t, folder, 1, start)
+	defer os.RemoveAll(folder + ".v2.bak")
+
+	assert.Equal(t, ErrVersion, Migrate(folder, 3), "v3 is not supported")
+}
+
+func TestMigrateResume(t *testing.T) {
+	folder := "test_migrate_resume"
+	defer os.RemoveAll(folder)
+	defer os.RemoveAll(folder + ".v1.bak")
+
+	start := createV1(t, folder)
+
+	// interrupted after converting one of the files
+	assert.NoError(t, writeFile(filepath.Join(folder, migrateMarker), []byte{1, 2}, false), "write marker wrong")
+	db, err := OpenWithOptions(folder, Options{ReadOnly: true})
+	assert.Equal(t, ErrMigrating, err, "should refuse an unfinished migration")
+	if db != nil {
+		db.Close()
+	}
+
+	slotsHome := filepath.Join(folder, slotsFolder)
+	aliases, err := readFolderNames(slotsHome)
+	assert.NoError(t, err, "read aliases wrong")
+	baseName := slotBaseName(filepath.Join(slotsHome, aliases[0]), encodeFileFromUnix(start, time.Local))
+	records, err := formatV1{}.read(baseName)
+	assert.NoError(t, err, "read wrong")
+	assert.NoError(t, formatV2{}.write(baseName, records), "write wrong")
+
+	assert.Error(t, Migrate(folder, 1), "another migration is unfinished")
+	assert.NoError(t, Migrate(folder, 2), "resume wrong")
+	checkMigrated(t, folder, 2, start)
+}
+
+func TestAutoMigrate(t *testing.T) {
+	folder := "test_auto_migrate"
+	defer os.RemoveAll(folder)
+	defer os.RemoveAll(folder + ".v1.bak")
+
+	start := createV1(t, folder)
+
+	db, err := OpenWithOptions(folder, Options{AutoMigrate: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	assert.NoError(t, db.Close(), "close wrong")
+	checkMigrated(t, folder, 2, start)
+
+	// a newer version is refused
+	meta, err := createInfo(filepath.Join(folder, metafile))
+	assert.NoError(t, err, "load meta wrong")
+	assert.NoError(t, meta.updateInfo([]string{versionKey}, [][]byte{{version + 1}}), "update meta wrong")
+	_, err = Open(folder)
+	assert.Equal(t, ErrVersion, err, "newer version should be refused")
+}
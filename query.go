@@ -0,0 +1,185 @@
+package tdb
+
+import (
+	"container/heap"
+	"context"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// Match selects targets by name, every field set should match.
+type Match struct {
+	Prefix string // names starting with it
+	Glob   string // names matching it as path.Match, "*" doesn't match "/"
+	Regexp string // names matching it as regexp
+}
+
+// MatchTargets returns the sorted targets matching.
+func (db *TDB) MatchTargets(match Match) ([]string, error) {
+	if err := db.check(false); err != nil {
+		return nil, err
+	}
+
+	var re *regexp.Regexp
+	if match.Regexp != "" {
+		var err error
+		if re, err = regexp.Compile(match.Regexp); err != nil {
+			return nil, err
+		}
+	}
+	if match.Glob != "" {
+		// check the pattern
+		if _, err := path.Match(match.Glob, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	// names out of the longest literal prefix can't match
+	prefix := match.Prefix
+	for _, p := range []string{globPrefix(match.Glob), regexpPrefix(match.Regexp)} {
+		if len(p) > len(prefix) && strings.HasPrefix(p, prefix) {
+			prefix = p
+		}
+	}
+
+	var names []string
+	for _, name := range db.targets.withPrefix(prefix) {
+		if !strings.HasPrefix(name, match.Prefix) {
+			continue
+		}
+		if match.Glob != "" {
+			if ok, _ := path.Match(match.Glob, name); !ok {
+				continue
+			}
+		}
+		if re != nil && !re.MatchString(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// the literal part of a glob before any special character
+func globPrefix(glob string) string {
+	if i := strings.IndexAny(glob, `*?[\`); i >= 0 {
+		return glob[:i]
+	}
+	return glob
+}
+
+// the literal prefix every match of a regexp anchored at the start of text begins with.
+// A line start may follow a newline within the name, so (?m)^ gives no prefix.
+func regexpPrefix(pattern string) string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil || re.Op != syntax.OpConcat || len(re.Sub) == 0 || re.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+
+	var prefix []rune
+	for _, sub := range re.Sub[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		prefix = append(prefix, sub.Rune...)
+	}
+	return string(prefix)
+}
+
+// QueryIterator walks the slots of several targets ordered by start.
+type QueryIterator struct {
+	its   slotHeap
+	opts  IterOptions
+	cur   Slot
+	count int
+	err   error
+}
+
+// Query returns an iterator over the slots of the matching targets starting between "start" and "end",
+// 0 "end" means no end. Slots of all targets are merged by start, ties are ordered by target.
+func (db *TDB) Query(ctx context.Context, match Match, start, end uint32, opts IterOptions) *QueryIterator {
+	targets, err := db.MatchTargets(match)
+	if err != nil {
+		return &QueryIterator{err: err}
+	}
+	return db.queryTargets(ctx, targets, start, end, opts)
+}
+
+// merge the slots of the targets by start
+func (db *TDB) queryTargets(ctx context.Context, targets []string, start, end uint32, opts IterOptions) *QueryIterator {
+	q := &QueryIterator{opts: opts}
+
+	// the limit applies to the merged slots
+	each := IterOptions{Reverse: opts.Reverse, Limit: opts.Limit}
+	for _, target := range targets {
+		it := db.IterSlots(ctx, target, start, end, each)
+		if it.Next() {
+			q.its.its = append(q.its.its, it)
+		} else if q.err = it.Err(); q.err != nil {
+			return q
+		}
+	}
+	q.its.reverse = opts.Reverse
+	heap.Init(&q.its)
+	return q
+}
+
+// Next moves to the next slot, false if there is no more or an error happened.
+func (q *QueryIterator) Next() bool {
+	if q.err != nil || q.its.Len() == 0 {
+		return false
+	}
+	if q.opts.Limit > 0 && q.count >= q.opts.Limit {
+		return false
+	}
+
+	it := q.its.its[0]
+	q.cur = it.Slot()
+	q.count++
+
+	if it.Next() {
+		heap.Fix(&q.its, 0)
+	} else if q.err = it.Err(); q.err == nil {
+		heap.Pop(&q.its)
+	}
+	// an error stops the iterator after the current slot
+	return true
+}
+
+// Slot returns the current slot.
+func (q *QueryIterator) Slot() Slot {
+	return q.cur
+}
+
+// Err returns the error stopping the iterator, if any.
+func (q *QueryIterator) Err() error {
+	return q.err
+}
+
+// slotHeap orders iterators by their current slot.
+type slotHeap struct {
+	its     []*SlotIterator
+	reverse bool
+}
+
+func (h *slotHeap) Len() int { return len(h.its) }
+
+func (h *slotHeap) Less(i, j int) bool {
+	a, b := h.its[i].Slot(), h.its[j].Slot()
+	if a.Start != b.Start {
+		return (a.Start < b.Start) != h.reverse
+	}
+	return a.Target < b.Target
+}
+
+func (h *slotHeap) Swap(i, j int) { h.its[i], h.its[j] = h.its[j], h.its[i] }
+
+func (h *slotHeap) Push(x interface{}) { h.its = append(h.its, x.(*SlotIterator)) }
+
+func (h *slotHeap) Pop() interface{} {
+	last := h.its[len(h.its)-1]
+	h.its = h.its[:len(h.its)-1]
+	return last
+}
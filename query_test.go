@@ -0,0 +1,110 @@
+package tdb
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchTargets(t *testing.T) {
+	folder := "test_match"
+	defer os.RemoveAll(folder)
+
+	start := uint32(1491134201)
+	createWithSlots(t, folder, map[string][][2]uint32{
+		"/home/me/proj/x.go":     {{start, 1}},
+		"/home/me/proj/sub/y.go": {{start, 1}},
+		"/home/me/other/z.go":    {{start, 1}},
+		"https://proj.io/":       {{start, 1}},
+	})
+
+	db, err := OpenWithOptions(folder, Options{ReadOnly: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		match    Match
+		expected []string
+	}{
+		{Match{}, []string{"/home/me/other/z.go", "/home/me/proj/sub/y.go", "/home/me/proj/x.go", "https://proj.io/"}},
+		{Match{Prefix: "/home/me/proj/"}, []string{"/home/me/proj/sub/y.go", "/home/me/proj/x.go"}},
+		{Match{Glob: "/home/me/*/*.go"}, []string{"/home/me/other/z.go", "/home/me/proj/x.go"}},
+		{Match{Regexp: "proj"}, []string{"/home/me/proj/sub/y.go", "/home/me/proj/x.go", "https://proj.io/"}},
+		{Match{Regexp: `^/home/.*/y\.go$`}, []string{"/home/me/proj/sub/y.go"}},
+		{Match{Prefix: "/home/", Regexp: "proj"}, []string{"/home/me/proj/sub/y.go", "/home/me/proj/x.go"}},
+		{Match{Prefix: "/none"}, nil},
+		{Match{Regexp: "^*"}, []string{"/home/me/other/z.go", "/home/me/proj/sub/y.go", "/home/me/proj/x.go", "https://proj.io/"}},
+		{Match{Regexp: "^+/home"}, []string{"/home/me/other/z.go", "/home/me/proj/sub/y.go", "/home/me/proj/x.go"}},
+		{Match{Regexp: "(?i)^/HOME/ME/P"}, []string{"/home/me/proj/sub/y.go", "/home/me/proj/x.go"}},
+	}
+	for _, tt := range tests {
+		names, err := db.MatchTargets(tt.match)
+		assert.NoError(t, err, "match wrong")
+		assert.Equal(t, tt.expected, names, "match %+v wrong", tt.match)
+	}
+
+	_, err = db.MatchTargets(Match{Glob: "["})
+	assert.Error(t, err, "bad glob")
+	_, err = db.MatchTargets(Match{Regexp: "("})
+	assert.Error(t, err, "bad regexp")
+
+	assert.Equal(t, "/home/", globPrefix("/home/*.go"), "glob prefix wrong")
+	for pattern, prefix := range map[string]string{
+		`^/home/.*/y\.go$`: "/home/",
+		`^/home/x*`:        "/home/",
+		"^*":               "",
+		"^{2}":             "",
+		"(?m)^/home":       "",
+		"/home":            "",
+		"(":                "",
+	} {
+		assert.Equal(t, prefix, regexpPrefix(pattern), "prefix of %s wrong", pattern)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	folder := "test_query"
+	defer os.RemoveAll(folder)
+
+	start := uint32(1491134201)
+	createWithSlots(t, folder, map[string][][2]uint32{
+		"/proj/a": {{start + 86400, 1}, {start, 2}},
+		"/proj/b": {{start, 3}, {start + 100, 4}},
+		"/other":  {{start + 50, 5}},
+	})
+
+	db, err := OpenWithOptions(folder, Options{ReadOnly: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	collectQuery := func(q *QueryIterator) []Slot {
+		var slots []Slot
+		for q.Next() {
+			slots = append(slots, q.Slot())
+		}
+		assert.NoError(t, q.Err(), "query wrong")
+		return slots
+	}
+
+	q := db.Query(ctx, Match{Prefix: "/proj/"}, 0, 0, IterOptions{})
+	assert.Equal(t, []Slot{
+		{"/proj/a", start, 2},
+		{"/proj/b", start, 3},
+		{"/proj/b", start + 100, 4},
+		{"/proj/a", start + 86400, 1},
+	}, collectQuery(q), "merged slots wrong")
+
+	q = db.Query(ctx, Match{}, 0, start+86399, IterOptions{Reverse: true, Limit: 2})
+	assert.Equal(t, []Slot{{"/proj/b", start + 100, 4}, {"/other", start + 50, 5}}, collectQuery(q), "reverse wrong")
+
+	q = db.Query(ctx, Match{Regexp: "("}, 0, 0, IterOptions{})
+	assert.False(t, q.Next(), "bad regexp")
+	assert.Error(t, q.Err(), "bad regexp")
+}
@@ -0,0 +1,231 @@
+package tdb
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// RenameTarget gives the slots and the open action of target "old" to target "new".
+// ErrTargetExist is returned if "new" already has slots or an action, use MergeTargets then.
+func (db *TDB) RenameTarget(old, new string) error {
+	if err := db.check(true); err != nil {
+		return err
+	}
+	if old == new {
+		return nil
+	}
+
+	// actions are locked before the targets and aliases, like AddAction adding a slot does
+	db.action.contentLock.Lock()
+	err := db.renameTarget(old, new, false)
+	db.action.contentLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	db.log("target renamed", zap.String("old", old), zap.String("new", new))
+	return db.compactActionsIfFull()
+}
+
+// move the alias and action of "old" to "new", or merge them into the ones of "new" if "merge" is true.
+// The caller holds the action content lock.
+func (db *TDB) renameTarget(old, new string, merge bool) error {
+	found, err := db.renameSlots(old, new, merge)
+	if err != nil {
+		return err
+	}
+
+	// the targets are unlocked, an action may be written as a slot
+	moved, err := db.moveAction(old, new)
+	if err != nil {
+		return err
+	}
+	if !found && !moved {
+		return ErrNotExist
+	}
+	return nil
+}
+
+// move the alias of "old" to "new", or merge the slots into the ones of "new" if "merge" is true.
+// Return whether "old" has slots, the caller holds the action content lock.
+func (db *TDB) renameSlots(old, new string, merge bool) (bool, error) {
+	unlock := lockTargets(old, new)
+	defer unlock()
+
+	_, hasAction := db.action.content[new]
+	newAlias := db.slot.getInfoValue(new)
+	if !merge && (newAlias != nil || hasAction) {
+		return false, ErrTargetExist
+	}
+
+	b := db.slot.getInfoValue(old)
+	if b == nil {
+		return false, nil
+	}
+	if merge && newAlias != nil {
+		return true, db.mergeTargets(old, new)
+	}
+
+	aliasedHome := filepath.Join(db.path, slotsFolder, string(b))
+	unlockAlias := srcLocker.WriteLock(aliasedHome)
+	defer unlockAlias()
+	if err := db.slot.moveInfo(old, new); err != nil {
+		return true, err
+	}
+	db.targets.remove(old)
+	db.targets.add(new)
+	return true, nil
+}
+
+// MergeTargets moves the slots and the open action of target "src" into target "dst" and deletes "src".
+// The records of the half-day files of both are interleaved in time order,
+// and the open actions are joined into one, unless they are apart by more than the expiry of "dst"
+// and the earlier one is written as a slot. If "dst" doesn't exist, "src" is renamed.
+func (db *TDB) MergeTargets(src, dst string) error {
+	if err := db.check(true); err != nil {
+		return err
+	}
+	if src == dst {
+		return nil
+	}
+
+	// actions are locked before the targets and aliases, like AddAction adding a slot does
+	db.action.contentLock.Lock()
+	err := db.renameTarget(src, dst, true)
+	db.action.contentLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	db.log("target merged", zap.String("src", src), zap.String("dst", dst))
+	return db.compactActionsIfFull()
+}
+
+// merge the slots of "src" into "dst" which both have slots, the caller locks both targets
+func (db *TDB) mergeTargets(src, dst string) error {
+	srcAlias := db.slot.getInfoValue(src)
+	dstAlias := db.slot.getInfoValue(dst)
+	srcHome := filepath.Join(db.path, slotsFolder, string(srcAlias))
+	dstHome := filepath.Join(db.path, slotsFolder, string(dstAlias))
+	for _, home := range sortedStrings(srcHome, dstHome) {
+		unlock := srcLocker.WriteLock(home)
+		defer unlock()
+	}
+
+	// every src file is removed once merged, so an interrupted merge can go on
+	err := db.writeRollups(func() error {
+		err := walkSlotFiles(srcHome, db.format.exts(), func(subFolder, fileName string) error {
+			file, err := encodeFromPath(subFolder, fileName)
+			if err != nil {
+				// not a half-day file, leave it to Verify
+				return nil
+			}
+			return db.mergeFile(srcHome, dstHome, file)
+		})
+		if err != nil {
+			return err
+		}
+		return db.mergeRollups(db.rollupsHome(srcHome), db.rollupsHome(dstHome))
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := db.slot.deleteInfo([]string{src}); err != nil {
+		return err
+	}
+	db.targets.remove(src)
+	return os.RemoveAll(srcHome)
+}
+
+// merge the records of a half-day file of "srcHome" into the one of "dstHome"
+func (db *TDB) mergeFile(srcHome, dstHome string, file fileEncode) error {
+	srcBase := slotBaseName(srcHome, file)
+	dstBase := slotBaseName(dstHome, file)
+
+	srcRecords, err := db.format.read(srcBase)
+	if err = db.reportCorruption(err); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	dstRecords, err := db.format.read(dstBase)
+	if err = db.reportCorruption(err); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	records := append(dstRecords, srcRecords...)
+	sort.SliceStable(records, func(i, j int) bool { return records[i].offset < records[j].offset })
+
+	subFolder, _ := file.path()
+	if err := createFolder(filepath.Join(dstHome, subFolder)); err != nil {
+		return err
+	}
+	if err := db.format.write(dstBase, records); err != nil {
+		return err
+	}
+	return removeExts(srcBase, db.format.exts())
+}
+
+// move the open action of "from" to "to", joined with the one of "to" if any.
+// If they are apart by more than the expiry of "to", the earlier one is written as a slot of "to".
+// Return whether "from" has an action, the caller holds the action content lock and no target lock.
+func (db *TDB) moveAction(from, to string) (bool, error) {
+	v, ok := db.action.content[from]
+	if !ok {
+		return false, nil
+	}
+	start, last, err := decodeAction(v)
+	if err != nil {
+		return true, err
+	}
+
+	if toV, ok := db.action.content[to]; ok {
+		toStart, toLast, err := decodeAction(toV)
+		if err != nil {
+			return true, err
+		}
+		// the earlier one first
+		if toStart > start {
+			start, last, toStart, toLast = toStart, toLast, start, last
+		}
+		if start > toLast && start-toLast > db.expiryFor(to) {
+			if err := db.addSlot(to, toStart, toLast-toStart); err != nil {
+				return true, err
+			}
+		} else {
+			start = toStart
+			if toLast > last {
+				last = toLast
+			}
+		}
+	}
+
+	v = encodeAction(start, last)
+	db.action.content[to] = v
+	if err := db.actionLog.append(journalSet, to, v); err != nil {
+		return true, err
+	}
+	return true, db.deleteActions([]string{from})
+}
+
+// write lock the targets in order, so no alias can be created for them meanwhile
+func lockTargets(targets ...string) func() {
+	var unlocks []func()
+	for _, target := range sortedStrings(targets...) {
+		unlocks = append(unlocks, srcLocker.WriteLock(target))
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+func sortedStrings(s ...string) []string {
+	sorted := make([]string, len(s))
+	copy(sorted, s)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,184 @@
+package tdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameTarget(t *testing.T) {
+	folder := "test_rename"
+	defer os.RemoveAll(folder)
+
+	start := uint32(1491134201)
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	assert.NoError(t, db.AddSlot("a", start, 20), "add slot wrong")
+	assert.NoError(t, db.AddAction("a", start+100), "add action wrong")
+	assert.NoError(t, db.AddSlot("b", start, 30), "add slot wrong")
+	alias := db.slot.getInfoValue("a")
+
+	assert.NoError(t, db.RenameTarget("a", "c"), "rename wrong")
+	assert.Equal(t, []string{"b", "c"}, db.GetTargets(), "targets wrong")
+	assert.Equal(t, alias, db.slot.getInfoValue("c"), "alias should be kept")
+	checkSlots(t, db, "c", [][2]uint32{{start, 20}})
+	targets, starts, _, err := db.GetActions()
+	assert.NoError(t, err, "get actions wrong")
+	assert.Equal(t, []string{"c"}, targets, "action should be renamed")
+	assert.Equal(t, []uint32{start + 100}, starts, "action wrong")
+
+	assert.Equal(t, ErrTargetExist, db.RenameTarget("c", "b"), "exist")
+	assert.Equal(t, ErrNotExist, db.RenameTarget("a", "d"), "not exist")
+	assert.NoError(t, db.RenameTarget("c", "c"), "same name")
+
+	// the renamed index is loaded the same
+	assert.NoError(t, db.Close(), "close wrong")
+	db, err = Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	assert.Equal(t, alias, db.slot.getInfoValue("c"), "alias should be kept")
+	assert.Nil(t, db.slot.getInfoValue("a"), "old name should be removed")
+}
+
+func TestMergeTargets(t *testing.T) {
+	folder := "test_merge_targets"
+	defer os.RemoveAll(folder)
+
+	start := uint32(1491134201)
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	assert.NoError(t, db.AddSlot("a", start+50, 10), "add slot wrong")
+	assert.NoError(t, db.AddSlot("a", start+86400, 20), "add slot wrong")
+	assert.NoError(t, db.AddAction("a", start+100), "add action wrong")
+	assert.NoError(t, db.AddAction("a", start+105), "add action wrong")
+	assert.NoError(t, db.AddSlot("b", start, 30), "add slot wrong")
+	assert.NoError(t, db.AddSlot("b", start+100, 40), "add slot wrong")
+	assert.NoError(t, db.AddAction("b", start+102), "add action wrong")
+	assert.NoError(t, db.AddAction("b", start+110), "add action wrong")
+	srcHome := filepath.Join(folder, slotsFolder, string(db.slot.getInfoValue("a")))
+
+	assert.NoError(t, db.MergeTargets("a", "b"), "merge wrong")
+	assert.Equal(t, []string{"b"}, db.GetTargets(), "targets wrong")
+	checkSlots(t, db, "b", [][2]uint32{{start, 30}, {start + 50, 10}, {start + 100, 40}, {start + 86400, 20}})
+	_, err = os.Stat(srcHome)
+	assert.True(t, os.IsNotExist(err), "src folder should be removed")
+
+	targets, starts, lasts, err := db.GetActions()
+	assert.NoError(t, err, "get actions wrong")
+	assert.Equal(t, []string{"b"}, targets, "actions wrong")
+	assert.Equal(t, []uint32{start + 100}, starts, "actions should be joined")
+	assert.Equal(t, []uint32{start + 110}, lasts, "actions should be joined")
+
+	// to a target not exist
+	assert.NoError(t, db.MergeTargets("b", "c"), "merge wrong")
+	checkSlots(t, db, "c", [][2]uint32{{start, 30}, {start + 50, 10}, {start + 100, 40}, {start + 86400, 20}})
+
+	report, err := db.Verify(context.Background())
+	assert.NoError(t, err, "verify wrong")
+	assert.True(t, report.OK(), "should have no problem: %v", report.Problems)
+}
+
+func TestMergeTargetsApartActions(t *testing.T) {
+	folder := "test_merge_apart_actions"
+	defer os.RemoveAll(folder)
+
+	clock := newFakeClock(time.Date(2017, 4, 2, 10, 0, 0, 0, time.UTC))
+	db, err := OpenWithOptions(folder, Options{Clock: clock})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := uint32(clock.Now().Unix())
+	assert.NoError(t, db.AddAction("src", now-3600), "add action wrong")
+	assert.NoError(t, db.AddAction("src", now-3590), "add action wrong")
+	assert.NoError(t, db.AddAction("dst", now-10), "add action wrong")
+	assert.NoError(t, db.AddAction("dst", now), "add action wrong")
+
+	// the earlier action expired before the later one began
+	assert.NoError(t, db.MergeTargets("src", "dst"), "merge wrong")
+	checkSlots(t, db, "dst", [][2]uint32{{now - 3600, 10}})
+	targets, starts, lasts, err := db.GetActions()
+	assert.NoError(t, err, "get actions wrong")
+	assert.Equal(t, []string{"dst"}, targets, "actions wrong")
+	assert.Equal(t, []uint32{now - 10}, starts, "later action should be kept")
+	assert.Equal(t, []uint32{now}, lasts, "later action should be kept")
+
+	// the other way around, the later one from "src"
+	assert.NoError(t, db.AddAction("other", now-7200), "add action wrong")
+	assert.NoError(t, db.MergeTargets("dst", "other"), "merge wrong")
+	// slots of a file are in the order written
+	checkSlots(t, db, "other", [][2]uint32{{now - 3600, 10}, {now - 7200, 1}})
+	targets, starts, lasts, err = db.GetActions()
+	assert.NoError(t, err, "get actions wrong")
+	assert.Equal(t, []string{"other"}, targets, "actions wrong")
+	assert.Equal(t, []uint32{now - 10}, starts, "later action should be kept")
+	assert.Equal(t, []uint32{now}, lasts, "later action should be kept")
+}
+
+func TestRenameTargetLockOrder(t *testing.T) {
+	folder := "test_rename_lock_order"
+	defer os.RemoveAll(folder)
+
+	// closing leaves the actions alone, so a failure doesn't hang on the locks
+	db, err := OpenWithOptions(folder, Options{KeepActions: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := uint32(1491134201)
+	assert.NoError(t, db.AddSlot("a", start, 10), "add slot wrong")
+	assert.NoError(t, db.AddSlot("b", start+20, 10), "add slot wrong")
+	assert.NoError(t, db.AddAction("a", start+100), "add action wrong")
+
+	// like AddAction turning an action into a slot, the slot of "target" is added under the action lock
+	// while "fn" runs
+	lockOrder := func(fn func() error, target string) error {
+		db.action.contentLock.Lock()
+		done := make(chan error, 1)
+		go func() {
+			done <- fn()
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		added := make(chan error, 1)
+		go func() {
+			added <- db.addSlot(target, start+200, 1)
+		}()
+		select {
+		case err := <-added:
+			assert.NoError(t, err, "add slot wrong")
+			db.action.contentLock.Unlock()
+		case <-time.After(5 * time.Second):
+			db.action.contentLock.Unlock()
+			t.Fatalf("deadlock with adding a slot of %s", target)
+		}
+		return <-done
+	}
+
+	// "c" gets a slot before the rename goes on
+	err = lockOrder(func() error { return db.RenameTarget("a", "c") }, "c")
+	assert.Equal(t, ErrTargetExist, err, "exist")
+
+	err = lockOrder(func() error { return db.MergeTargets("a", "c") }, "c")
+	assert.NoError(t, err, "merge wrong")
+	checkSlots(t, db, "c", [][2]uint32{{start, 10}, {start + 200, 1}, {start + 200, 1}})
+	assert.Equal(t, []string{"b", "c"}, db.GetTargets(), "targets wrong")
+	targets, _, _, err := db.GetActions()
+	assert.NoError(t, err, "get actions wrong")
+	assert.Equal(t, []string{"c"}, targets, "action should be moved")
+}
@@ -0,0 +1,136 @@
+package tdb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// get the size of a file, 0 if not exist
+func fileSize(fullPath string) (int64, error) {
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return stat.Size(), nil
+}
+
+// report a *CorruptionError through OnError instead of failing the call,
+// it is logged as a warning if OnError is nil.
+func (db *TDB) reportCorruption(err error) error {
+	if cerr, ok := err.(*CorruptionError); ok {
+		if db.opts.OnError == nil {
+			db.warn("corrupted slot file", zap.Error(cerr))
+			return nil
+		}
+		db.report(cerr)
+		return nil
+	}
+	return err
+}
+
+// repairSlots checks every half-day file of all targets and truncates torn ones.
+func (db *TDB) repairSlots() error {
+	slotsHome := filepath.Join(db.path, slotsFolder)
+	aliases, err := readFolderNames(slotsHome)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, alias := range aliases {
+		aliasedHome := filepath.Join(slotsHome, alias)
+		if _, err := checkFolderExist(aliasedHome); err == ErrPathNotFolder {
+			continue
+		}
+
+		err := walkSlotFiles(aliasedHome, db.format.exts(), func(subFolder, fileName string) error {
+			file, err := encodeFromPath(subFolder, fileName)
+			if err != nil {
+				// not a half-day file, leave it to Verify
+				return nil
+			}
+
+			unlock := srcLocker.WriteLock(encodeAliasAndFile(aliasedHome, file))
+			defer unlock()
+
+			torn, err := db.format.repair(filepath.Join(aliasedHome, subFolder, fileName))
+			if err != nil {
+				return err
+			}
+			if torn != nil {
+				db.report(torn)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkSlotFiles calls fn once for every half-day file with one of the exts under the aliased home,
+// with the year-month folder name and the file name without ext.
+func walkSlotFiles(aliasedHome string, exts []string, fn func(subFolder, fileName string) error) error {
+	folders, err := readFolderNames(aliasedHome)
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range folders {
+		subFolder := filepath.Join(aliasedHome, folder)
+		if _, err := checkFolderExist(subFolder); err == ErrPathNotFolder {
+			continue
+		}
+
+		names, err := readFolderNames(subFolder)
+		if err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool)
+		for _, n := range names {
+			ext := filepath.Ext(n)
+			if !hasExt(exts, ext) {
+				continue
+			}
+			base := strings.TrimSuffix(n, ext)
+			if seen[base] {
+				continue
+			}
+			seen[base] = true
+			if err := fn(folder, base); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hasExt(exts []string, ext string) bool {
+	for _, e := range exts {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// read the names in a folder
+func readFolderNames(folder string) ([]string, error) {
+	f, err := os.Open(folder)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return f.Readdirnames(0)
+}
@@ -0,0 +1,121 @@
+package tdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTornSlotFile(t *testing.T) {
+	folder := "test_torn_slot"
+	defer os.RemoveAll(folder)
+
+	var reported []error
+	db, err := OpenWithOptions(folder, Options{Version: 1, OnError: func(err error) { reported = append(reported, err) }})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	target := "abc.edf"
+	start := uint32(1491134201)
+	assert.NoError(t, db.AddSlot(target, start, 20), "should have no error")
+	assert.NoError(t, db.AddSlot(target, start+30, 10), "should have no error")
+
+	aliasedHome, err := db.getAliasedHome(target)
+	assert.NoError(t, err, "fail to get target home folder")
+	file := encodeFileFromUnix(start, db.loc)
+	baseName := slotBaseName(aliasedHome, file)
+
+	// crash between the two appends, idx has one more record
+	assert.NoError(t, appendToFile(baseName+offsetExt, []byte{1, 0}, false), "append wrong")
+
+	// reading returns the consistent records and reports the corruption
+	starts, slots, err := db.GetSlots(target, 0, 0)
+	assert.NoError(t, err, "should have no error")
+	assert.Equal(t, [][]uint32{{start, start + 30}}, starts, "starts wrong")
+	assert.Equal(t, [][]uint32{{20, 10}}, slots, "slots wrong")
+	if assert.Len(t, reported, 1, "corruption should be reported") {
+		cerr, ok := reported[0].(*CorruptionError)
+		assert.True(t, ok, "should be a corruption error")
+		assert.Equal(t, 2, cerr.Records, "consistent records wrong")
+		assert.False(t, cerr.Repaired, "should not be repaired by reading")
+	}
+
+	// writing repairs before appending
+	assert.NoError(t, db.AddSlot(target, start+60, 5), "should have no error")
+	assert.Len(t, reported, 2, "repair should be reported")
+	starts, slots, err = db.GetSlots(target, 0, 0)
+	assert.NoError(t, err, "should have no error")
+	assert.Equal(t, [][]uint32{{start, start + 30, start + 60}}, starts, "starts wrong")
+	assert.Equal(t, [][]uint32{{20, 10, 5}}, slots, "slots wrong")
+	assert.Len(t, reported, 2, "should be consistent after repair")
+
+	// partial slt write found on open
+	assert.NoError(t, appendToFile(baseName+slotExt, []byte{1, 2}, false), "append wrong")
+	db2, err := OpenWithOptions(folder, Options{Repair: true, OnError: func(err error) { reported = append(reported, err) }})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+	if assert.Len(t, reported, 3, "repair on open should be reported") {
+		cerr, ok := reported[2].(*CorruptionError)
+		assert.True(t, ok, "should be a corruption error")
+		assert.Equal(t, 3, cerr.Records, "consistent records wrong")
+		assert.True(t, cerr.Repaired, "should be repaired")
+	}
+
+	size, err := fileSize(baseName + slotExt)
+	assert.NoError(t, err, "should have no error")
+	assert.Equal(t, int64(3*slotBytes), size, "slt should be truncated")
+
+	// missing slt file
+	single := encodeFile(2017, 4, 12, 1)
+	assert.NoError(t, createFolder(filepath.Dir(slotBaseName(aliasedHome, single))), "create folder wrong")
+	assert.NoError(t, appendToFile(slotBaseName(aliasedHome, single)+offsetExt, []byte{1, 0}, false), "append wrong")
+	singleStarts, _, err := readSlots(db.format, aliasedHome, single, db.loc)
+	assert.Len(t, singleStarts, 0, "should have no record")
+	assert.IsType(t, &CorruptionError{}, err, "should be torn")
+}
+
+func TestCorruptionWarning(t *testing.T) {
+	folder := "test_corruption_warning"
+	defer os.RemoveAll(folder)
+
+	core, logs := observer.New(zap.WarnLevel)
+	db, err := OpenWithOptions(folder, Options{Version: 1, Logger: zap.New(core)})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	target := "abc.edf"
+	start := uint32(1491134201)
+	assert.NoError(t, db.AddSlot(target, start, 20), "should have no error")
+	aliasedHome, err := db.getAliasedHome(target)
+	assert.NoError(t, err, "fail to get target home folder")
+	baseName := slotBaseName(aliasedHome, encodeFileFromUnix(start, db.loc))
+	assert.NoError(t, appendToFile(baseName+offsetExt, []byte{1, 0}, false), "append wrong")
+
+	// without OnError the corruption is logged as a warning
+	_, _, err = db.GetSlots(target, 0, 0)
+	assert.NoError(t, err, "should have no error")
+	if assert.Equal(t, 1, logs.Len(), "corruption should be logged") {
+		entry := logs.All()[0]
+		assert.Equal(t, "corrupted slot file", entry.Message, "message wrong")
+		assert.Contains(t, entry.ContextMap()["error"], baseName, "error wrong")
+	}
+}
+
+func TestCorruptionErrorMessage(t *testing.T) {
+	err := &CorruptionError{Path: "slots/a/201704/020", Records: 2, Torn: true}
+	assert.Equal(t, "slot file slots/a/201704/020 is torn, 2 records are consistent", err.Error(), "torn message wrong")
+
+	err = &CorruptionError{Path: "slots/a/201704/020", Records: 2, Torn: true, Bad: 1, Repaired: true}
+	assert.Equal(t, "slot file slots/a/201704/020 is torn and has 1 records failing the checksum, 2 records are consistent, truncated to them",
+		err.Error(), "message wrong")
+}
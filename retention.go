@@ -0,0 +1,201 @@
+package tdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RetentionRule sets how long the slots of targets starting with Prefix are kept.
+// The rule with the longest matching prefix wins.
+type RetentionRule struct {
+	Prefix string
+	Keep   time.Duration // 0 keeps them forever
+}
+
+// PruneStats of Prune.
+type PruneStats struct {
+	Folders int // year-month folders removed
+	Files   int // half-day files removed
+	Rollups int // yearly rollup files removed
+	Targets int // targets removed as they have no slot left
+}
+
+// get the retention of a target, 0 means forever
+func (db *TDB) retentionFor(target string) time.Duration {
+	for _, rule := range db.retentionRules {
+		if strings.HasPrefix(target, rule.Prefix) {
+			return rule.Keep
+		}
+	}
+	return db.opts.Retention
+}
+
+// sort the retention rules with the longest prefix first
+func (db *TDB) loadRetention() {
+	db.retentionRules = make([]RetentionRule, len(db.opts.RetentionRules))
+	copy(db.retentionRules, db.opts.RetentionRules)
+	sort.SliceStable(db.retentionRules, func(i, j int) bool {
+		return len(db.retentionRules[i].Prefix) > len(db.retentionRules[j].Prefix)
+	})
+}
+
+// Prune removes the half-day files of every target ending before its retention,
+// whole year-month folders are removed when they can be. Targets left without slot files
+// are removed from the index unless they have rollups, their open actions are kept.
+func (db *TDB) Prune(ctx context.Context) (PruneStats, error) {
+	if err := db.check(true); err != nil {
+		return PruneStats{}, err
+	}
+	return db.prune(ctx)
+}
+
+func (db *TDB) prune(ctx context.Context) (PruneStats, error) {
+	var stats PruneStats
+	now := db.clock.Now()
+
+	for _, target := range db.GetTargets() {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		keep := db.retentionFor(target)
+		if keep <= 0 {
+			continue
+		}
+		cutoff := now.Add(-keep).Unix()
+		if cutoff <= 0 {
+			continue
+		}
+		if err := db.pruneTarget(target, uint32(cutoff), now, &stats); err != nil {
+			return stats, err
+		}
+	}
+
+	db.log("pruned", zap.Int("folders", stats.Folders), zap.Int("files", stats.Files), zap.Int("targets", stats.Targets))
+	return stats, nil
+}
+
+// remove the files of a target ending before "cutoff"
+func (db *TDB) pruneTarget(target string, cutoff uint32, now time.Time, stats *PruneStats) error {
+	unlockTarget := srcLocker.WriteLock(target)
+	defer unlockTarget()
+
+	b := db.slot.getInfoValue(target)
+	if b == nil {
+		return nil
+	}
+	aliasedHome := filepath.Join(db.path, slotsFolder, string(b))
+	unlock := srcLocker.WriteLock(aliasedHome)
+	defer unlock()
+
+	// the file holding the cutoff is kept, all before it are removed
+	keepFile := encodeFileFromUnix(cutoff, db.loc)
+	keepFolder, _ := keepFile.path()
+	fRange, err := newFileRange(0, cutoff, db.loc)
+	if err != nil {
+		return err
+	}
+
+	folders, err := getInRangeFolders(fRange, aliasedHome)
+	if err != nil {
+		return err
+	}
+	for _, folder := range folders {
+		if folder != keepFolder {
+			if err := os.RemoveAll(filepath.Join(aliasedHome, folder)); err != nil {
+				return err
+			}
+			stats.Folders++
+			continue
+		}
+
+		files, err := getInRangeFiles(fRange, aliasedHome, folder, db.format.exts()[0])
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if file >= keepFile {
+				continue
+			}
+			if err := removeExts(slotBaseName(aliasedHome, file), db.format.exts()); err != nil {
+				return err
+			}
+			stats.Files++
+		}
+	}
+
+	// rollups are kept for RollupRetention by whole years
+	rollupsHome := db.rollupsHome(aliasedHome)
+	if db.opts.RollupRetention > 0 {
+		cutoffYear := now.Add(-db.opts.RollupRetention).In(db.fixedLoc).Year()
+		if err := pruneRollups(rollupsHome, cutoffYear, stats); err != nil {
+			return err
+		}
+	}
+
+	// remove the target without slot files, it is kept while it has rollups
+	if exist, err := checkFolderExist(rollupsHome); err != nil || exist {
+		return err
+	}
+	names, err := readFolderNames(aliasedHome)
+	if err != nil {
+		return err
+	}
+	for _, folder := range names {
+		left, err := readFolderNames(filepath.Join(aliasedHome, folder))
+		if err != nil || len(left) > 0 {
+			return err
+		}
+	}
+	if err := db.slot.deleteInfo([]string{target}); err != nil {
+		return err
+	}
+	db.targets.remove(target)
+	stats.Targets++
+	return os.RemoveAll(aliasedHome)
+}
+
+// remove the rollup files of years before "cutoffYear", and the folder if none is left
+func pruneRollups(home string, cutoffYear int, stats *PruneStats) error {
+	names, err := readFolderNames(home)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	left := len(names)
+	for _, name := range names {
+		ext := filepath.Ext(name)
+		if ext != dayExt && ext != monthExt {
+			continue
+		}
+		year, err := strconv.Atoi(strings.TrimSuffix(name, ext))
+		if err != nil || year >= cutoffYear {
+			continue
+		}
+
+		fullPath := filepath.Join(home, name)
+		unlock := srcLocker.WriteLock(fullPath)
+		err = os.Remove(fullPath)
+		unlock()
+		if err != nil {
+			return err
+		}
+		stats.Rollups++
+		left--
+	}
+
+	if left == 0 {
+		return os.Remove(home)
+	}
+	return nil
+}
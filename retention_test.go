@@ -0,0 +1,104 @@
+package tdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrune(t *testing.T) {
+	folder := "test_prune"
+	defer os.RemoveAll(folder)
+
+	now := time.Date(2017, 6, 15, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(now)
+	db, err := OpenWithOptions(folder, Options{
+		Zone:           ZoneUTC,
+		Clock:          clock,
+		Retention:      30 * 24 * time.Hour,
+		RetentionRules: []RetentionRule{{Prefix: "raw/", Keep: 0}, {Prefix: "raw/short/", Keep: time.Hour}},
+	})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	unix := func(month time.Month, day, hour int) uint32 {
+		return uint32(time.Date(2017, month, day, hour, 0, 0, 0, time.UTC).Unix())
+	}
+	add := func(target string, starts ...uint32) {
+		for _, s := range starts {
+			assert.NoError(t, db.AddSlot(target, s, 10), "add slot wrong")
+		}
+	}
+	// the cutoff of 30 days is 2017-05-16 12:00
+	add("a", unix(4, 2, 10), unix(5, 16, 10), unix(5, 16, 14), unix(6, 1, 10))
+	add("raw/x", unix(4, 2, 10))
+	add("raw/short/y", unix(6, 14, 22), unix(6, 15, 11))
+	add("old", unix(4, 2, 10))
+	oldHome := filepath.Join(folder, slotsFolder, string(db.slot.getInfoValue("old")))
+
+	stats, err := db.Prune(context.Background())
+	assert.NoError(t, err, "prune wrong")
+	assert.Equal(t, PruneStats{Folders: 2, Files: 2}, stats, "stats wrong")
+
+	checkSlots(t, db, "a", [][2]uint32{{unix(5, 16, 14), 10}, {unix(6, 1, 10), 10}})
+	checkSlots(t, db, "raw/x", [][2]uint32{{unix(4, 2, 10), 10}})
+	checkSlots(t, db, "raw/short/y", [][2]uint32{{unix(6, 15, 11), 10}})
+	checkSlots(t, db, "old", nil)
+
+	// the rollups of pruned slots are kept
+	assert.Equal(t, []string{"a", "old", "raw/short/y", "raw/x"}, db.GetTargets(), "old should be kept")
+	rollups, err := db.Rollups("a", 0, 0, RollupMonth)
+	assert.NoError(t, err, "rollups wrong")
+	assert.Len(t, rollups, 3, "rollups should be kept")
+
+	stats, err = db.Prune(context.Background())
+	assert.NoError(t, err, "prune wrong")
+	assert.Equal(t, PruneStats{}, stats, "nothing to prune")
+
+	report, err := db.Verify(context.Background())
+	assert.NoError(t, err, "verify wrong")
+	assert.True(t, report.OK(), "should have no problem: %v", report.Problems)
+
+	// rollups of 2017 are removed in 2019, then targets left without slots
+	db.opts.RollupRetention = 365 * 24 * time.Hour
+	clock.now = time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+	stats, err = db.Prune(context.Background())
+	assert.NoError(t, err, "prune wrong")
+	assert.Equal(t, PruneStats{Folders: 3, Rollups: 6, Targets: 3}, stats, "stats wrong")
+	assert.Equal(t, []string{"raw/x"}, db.GetTargets(), "targets wrong")
+	_, err = os.Stat(oldHome)
+	assert.True(t, os.IsNotExist(err), "old folder should be removed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.Prune(ctx)
+	assert.Equal(t, context.Canceled, err, "cancelled")
+}
+
+func TestPruneWorker(t *testing.T) {
+	folder := "test_prune_worker"
+	defer os.RemoveAll(folder)
+
+	clock := newFakeClock(time.Now())
+	db, err := OpenWithOptions(folder, Options{
+		Clock:         clock,
+		Retention:     time.Hour,
+		PruneInterval: time.Minute,
+	})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	assert.NoError(t, db.AddSlot("a", uint32(time.Now().Add(-48*time.Hour).Unix()), 10), "add slot wrong")
+	clock.tick()
+	starts, _, err := db.GetSlots("a", 0, 0)
+	assert.NoError(t, err, "get slots wrong")
+	assert.Empty(t, starts, "should be pruned by the worker")
+}
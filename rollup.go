@@ -0,0 +1,563 @@
+package tdb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// rollups of an alias are kept in "rollups/<alias>", out of the slots folder
+	rollupsFolder = "rollups"
+
+	// set to 1 once the rollups hold every slot
+	rollupsKey = "rollups"
+
+	// present while slots and rollups are written, until closed
+	rollupsMarker = "__rollups_dirty__"
+
+	dayExt   = ".day" // a year of daily rollups
+	monthExt = ".mon" // a year of monthly rollups
+
+	// seconds(8), count(4), first(4), last(4), crc32(4)
+	rollupBytes = 24
+)
+
+// RollupPeriod is the period of a rollup.
+type RollupPeriod uint8
+
+// Periods of rollups, starting at the boundaries of the database zone offset.
+const (
+	RollupDay RollupPeriod = iota
+	RollupMonth
+)
+
+// Rollup sums the slots of a target in a day or a month.
+type Rollup struct {
+	Start   uint32 // start of the period
+	Seconds uint64 // active seconds in the period
+	Count   uint32 // slots starting in the period
+	First   uint32 // first active second
+	Last    uint32 // end of the last activity
+}
+
+func (r Rollup) empty() bool {
+	return r.Seconds == 0 && r.Count == 0
+}
+
+// add the activity of another rollup of the same period
+func (r *Rollup) add(o Rollup) {
+	if r.empty() {
+		r.Seconds, r.Count, r.First, r.Last = o.Seconds, o.Count, o.First, o.Last
+		return
+	}
+	if o.empty() {
+		return
+	}
+	r.Seconds += o.Seconds
+	r.Count += o.Count
+	if o.First < r.First {
+		r.First = o.First
+	}
+	if o.Last > r.Last {
+		r.Last = o.Last
+	}
+}
+
+func encodeRollup(r Rollup) []byte {
+	b := make([]byte, rollupBytes)
+	binary.LittleEndian.PutUint64(b, r.Seconds)
+	binary.LittleEndian.PutUint32(b[8:], r.Count)
+	binary.LittleEndian.PutUint32(b[12:], r.First)
+	binary.LittleEndian.PutUint32(b[16:], r.Last)
+	binary.LittleEndian.PutUint32(b[20:], crc32.ChecksumIEEE(b[:20]))
+	return b
+}
+
+// decode a rollup, all zero bytes are an empty one
+func decodeRollup(b []byte) (Rollup, bool) {
+	var r Rollup
+	if crc32.ChecksumIEEE(b[:20]) != binary.LittleEndian.Uint32(b[20:]) {
+		for _, v := range b {
+			if v != 0 {
+				return r, false
+			}
+		}
+		return r, true
+	}
+	r.Seconds = binary.LittleEndian.Uint64(b)
+	r.Count = binary.LittleEndian.Uint32(b[8:])
+	r.First = binary.LittleEndian.Uint32(b[12:])
+	r.Last = binary.LittleEndian.Uint32(b[16:])
+	return r, true
+}
+
+// rollupKey locates a rollup, "index" is the day of the year or the month from 0.
+type rollupKey struct {
+	period RollupPeriod
+	year   int
+	index  int
+}
+
+func (k rollupKey) fileName() string {
+	if k.period == RollupDay {
+		return strconv.Itoa(k.year) + dayExt
+	}
+	return strconv.Itoa(k.year) + monthExt
+}
+
+// start of the period in the location
+func (k rollupKey) start(loc *time.Location) uint32 {
+	if k.period == RollupDay {
+		return uint32(time.Date(k.year, 1, 1+k.index, 0, 0, 0, 0, loc).Unix())
+	}
+	return uint32(time.Date(k.year, time.Month(1+k.index), 1, 0, 0, 0, 0, loc).Unix())
+}
+
+// get the key of the period containing "unix" and the start of the next period
+func periodOf(unix uint64, period RollupPeriod, loc *time.Location) (rollupKey, uint64) {
+	bucket := BucketDay
+	if period == RollupMonth {
+		bucket = BucketMonth
+	}
+	start, next := bucketOf(int64(unix), bucket, loc)
+
+	t := time.Unix(int64(start), 0).In(loc)
+	key := rollupKey{period: period, year: t.Year(), index: t.YearDay() - 1}
+	if period == RollupMonth {
+		key.index = int(t.Month()) - 1
+	}
+	return key, next
+}
+
+// add a slot to the daily and monthly rollups, the seconds are split by the periods
+func addSlotRollups(acc map[rollupKey]Rollup, start, howlong uint32, loc *time.Location) {
+	for _, period := range []RollupPeriod{RollupDay, RollupMonth} {
+		from := uint64(start)
+		to := from + uint64(howlong)
+		count := uint32(1)
+		for {
+			key, next := periodOf(from, period, loc)
+			end := to
+			if next < end {
+				end = next
+			}
+			last := end
+			if last > math.MaxUint32 {
+				last = math.MaxUint32
+			}
+
+			r := acc[key]
+			r.add(Rollup{Seconds: end - from, Count: count, First: uint32(from), Last: uint32(last)})
+			acc[key] = r
+
+			if next >= to {
+				break
+			}
+			from, count = next, 0
+		}
+	}
+}
+
+// get the rollups home of an aliased home
+func (db *TDB) rollupsHome(aliasedHome string) string {
+	return filepath.Join(db.path, rollupsFolder, filepath.Base(aliasedHome))
+}
+
+// add slots to the rollups of the alias
+func (db *TDB) rollupSlots(aliasedHome string, records []absRecord) error {
+	acc := make(map[rollupKey]Rollup)
+	for _, r := range records {
+		addSlotRollups(acc, r.start, r.howlong, db.fixedLoc)
+	}
+	return db.addRollups(db.rollupsHome(aliasedHome), acc)
+}
+
+// replace the slots of a rewritten half-day file in the rollups of the alias.
+// The new slots cover the old ones, so only the seconds and counts of the old are taken out.
+func (db *TDB) rerollSlots(aliasedHome string, old, new []absRecord) error {
+	oldAcc := make(map[rollupKey]Rollup)
+	for _, r := range old {
+		addSlotRollups(oldAcc, r.start, r.howlong, db.fixedLoc)
+	}
+	acc := make(map[rollupKey]Rollup)
+	for _, r := range new {
+		addSlotRollups(acc, r.start, r.howlong, db.fixedLoc)
+	}
+	for key := range oldAcc {
+		if _, ok := acc[key]; !ok {
+			acc[key] = Rollup{}
+		}
+	}
+	return db.updateRollups(db.rollupsHome(aliasedHome), acc, oldAcc)
+}
+
+// add rollups to the ones in the files of "home", one file at a time
+func (db *TDB) addRollups(home string, acc map[rollupKey]Rollup) error {
+	return db.updateRollups(home, acc, nil)
+}
+
+// add the rollups of "acc" and take out the seconds and counts of "sub"
+func (db *TDB) updateRollups(home string, acc, sub map[rollupKey]Rollup) error {
+	if len(acc) == 0 {
+		return nil
+	}
+	if err := createFolder(home); err != nil {
+		return err
+	}
+
+	files := make(map[string][]rollupKey)
+	for key := range acc {
+		files[key.fileName()] = append(files[key.fileName()], key)
+	}
+	for name, keys := range files {
+		if err := db.updateRollupFile(filepath.Join(home, name), keys, acc, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *TDB) updateRollupFile(fullPath string, keys []rollupKey, acc, sub map[rollupKey]Rollup) error {
+	unlock := srcLocker.WriteLock(fullPath)
+	defer unlock()
+
+	f, err := os.OpenFile(fullPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b := make([]byte, rollupBytes)
+	for _, key := range keys {
+		at := int64(key.index) * rollupBytes
+		if _, err := f.ReadAt(b, at); err != nil && err != io.EOF {
+			return err
+		} else if err == io.EOF {
+			b = make([]byte, rollupBytes)
+		}
+
+		r, ok := decodeRollup(b)
+		if !ok {
+			db.report(fmt.Errorf("rollup %d of %s fails the checksum, it is reset", key.index, fullPath))
+		}
+		r.add(acc[key])
+		if o, ok := sub[key]; ok {
+			r.Seconds -= o.Seconds
+			r.Count -= o.Count
+			if r.empty() {
+				r = Rollup{}
+			}
+		}
+		if _, err := f.WriteAt(encodeRollup(r), at); err != nil {
+			return err
+		}
+	}
+
+	if db.opts.Sync {
+		return f.Sync()
+	}
+	return nil
+}
+
+// read the rollups of a file, the empty ones are left out
+func readRollupFile(fullPath string, period RollupPeriod, year int, loc *time.Location) ([]Rollup, error) {
+	unlock := srcLocker.ReadLock(fullPath)
+	b, err := ioutil.ReadFile(fullPath)
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var rollups []Rollup
+	for i := 0; i+rollupBytes <= len(b); i += rollupBytes {
+		r, ok := decodeRollup(b[i : i+rollupBytes])
+		if !ok || r.empty() {
+			continue
+		}
+		r.Start = rollupKey{period, year, i / rollupBytes}.start(loc)
+		rollups = append(rollups, r)
+	}
+	return rollups, nil
+}
+
+// Rollups returns the daily or monthly rollups of a target starting between "start" and "end",
+// 0 "end" means no end. They are ordered by start, periods without activity are left out.
+// Rollups are complete only if RollupsComplete is true, see RebuildRollups.
+func (db *TDB) Rollups(target string, start, end uint32, period RollupPeriod) ([]Rollup, error) {
+	if err := db.check(false); err != nil {
+		return nil, err
+	}
+	if period != RollupDay && period != RollupMonth {
+		return nil, fmt.Errorf("unknown rollup period %d", period)
+	}
+	if end == 0 {
+		end = math.MaxUint32
+	}
+	if end < start {
+		return nil, ErrRange
+	}
+
+	aliasedHome, unlock, err := db.lockAlias(target, false)
+	if err != nil || aliasedHome == "" {
+		return nil, err
+	}
+	defer unlock()
+	return db.readRollups(db.rollupsHome(aliasedHome), start, end, period)
+}
+
+func (db *TDB) readRollups(home string, start, end uint32, period RollupPeriod) ([]Rollup, error) {
+	ext := dayExt
+	if period == RollupMonth {
+		ext = monthExt
+	}
+	fromYear := time.Unix(int64(start), 0).In(db.fixedLoc).Year()
+	toYear := time.Unix(int64(end), 0).In(db.fixedLoc).Year()
+
+	names, err := readFolderNames(home)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var rollups []Rollup
+	for _, name := range names {
+		if filepath.Ext(name) != ext {
+			continue
+		}
+		year, err := strconv.Atoi(strings.TrimSuffix(name, ext))
+		if err != nil || year < fromYear || year > toYear {
+			continue
+		}
+
+		rs, err := readRollupFile(filepath.Join(home, name), period, year, db.fixedLoc)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rs {
+			if r.Start >= start && r.Start <= end {
+				rollups = append(rollups, r)
+			}
+		}
+	}
+	return rollups, nil
+}
+
+// RollupsComplete reports whether the rollups hold every slot, it is false while slots are written.
+// Databases created before rollups, or whose rollups failed to be written or were left
+// in the middle of a write, need RebuildRollups once.
+func (db *TDB) RollupsComplete() bool {
+	db.rollupLock.Lock()
+	defer db.rollupLock.Unlock()
+	return db.rollupWriters == 0 && !db.rollupsBroken && db.rollupsFlag()
+}
+
+func (db *TDB) rollupsFlag() bool {
+	b := db.meta.getInfoValue(rollupsKey)
+	return len(b) == 1 && b[0] == 1
+}
+
+// check the dirty marker left by writes of slots and rollups when the database wasn't closed.
+// The rollups may miss some slots then, they are marked incomplete, or taken as so if read-only.
+func (db *TDB) checkRollupsMarker() error {
+	marker := filepath.Join(db.path, rollupsMarker)
+	if _, err := os.Stat(marker); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if db.opts.ReadOnly {
+		db.rollupsBroken = true
+		return nil
+	}
+	if err := db.meta.deleteInfo([]string{rollupsKey}); err != nil {
+		return err
+	}
+	return os.Remove(marker)
+}
+
+// create the dirty marker before the first write of slots and rollups,
+// so a write interrupted between the two leaves the rollups to RebuildRollups
+func (db *TDB) beginRollupWrite() error {
+	db.rollupLock.Lock()
+	defer db.rollupLock.Unlock()
+
+	if !db.rollupsMarked && db.rollupsFlag() {
+		if err := ioutil.WriteFile(filepath.Join(db.path, rollupsMarker), nil, 0644); err != nil {
+			return err
+		}
+		db.rollupsMarked = true
+	}
+	db.rollupWriters++
+	return nil
+}
+
+// mark the rollups incomplete if a write failed
+func (db *TDB) endRollupWrite(err error) error {
+	db.rollupLock.Lock()
+	defer db.rollupLock.Unlock()
+
+	db.rollupWriters--
+	if err != nil {
+		db.rollupsFailed = true
+		if derr := db.meta.deleteInfo([]string{rollupsKey}); derr != nil {
+			db.report(derr)
+		}
+	}
+	return err
+}
+
+// run "fn" which writes slots and their rollups
+func (db *TDB) writeRollups(fn func() error) error {
+	if err := db.beginRollupWrite(); err != nil {
+		return err
+	}
+	return db.endRollupWrite(fn())
+}
+
+// remove the dirty marker once no write is in flight, when closing
+func (db *TDB) removeRollupsMarker() error {
+	db.rollupLock.Lock()
+	defer db.rollupLock.Unlock()
+
+	if !db.rollupsMarked || db.rollupWriters != 0 {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(db.path, rollupsMarker)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db.rollupsMarked = false
+	return nil
+}
+
+// RebuildRollups rebuilds the rollups of every target from its slot files.
+// Periods whose slot files were pruned are lost.
+func (db *TDB) RebuildRollups(ctx context.Context) error {
+	if err := db.check(true); err != nil {
+		return err
+	}
+
+	// incomplete until all are rebuilt
+	db.rollupLock.Lock()
+	db.rollupsFailed = false
+	err := db.meta.deleteInfo([]string{rollupsKey})
+	db.rollupLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, target := range db.GetTargets() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		unlockTarget := srcLocker.WriteLock(target)
+		var err error
+		if b := db.slot.getInfoValue(target); b != nil {
+			aliasedHome := filepath.Join(db.path, slotsFolder, string(b))
+			unlock := srcLocker.WriteLock(aliasedHome)
+			err = db.rebuildRollups(aliasedHome)
+			unlock()
+		}
+		unlockTarget()
+		if err != nil {
+			return err
+		}
+	}
+
+	db.log("rollups rebuilt")
+	db.rollupLock.Lock()
+	defer db.rollupLock.Unlock()
+	if db.rollupsFailed {
+		// a write failed meanwhile, maybe on a rebuilt target
+		return errors.New("rollups failed to be written while rebuilt")
+	}
+	return db.meta.updateInfo([]string{rollupsKey}, [][]byte{{1}})
+}
+
+// rebuild the rollups of an alias from its slot files, the caller write locks the alias
+func (db *TDB) rebuildRollups(aliasedHome string) error {
+	acc := make(map[rollupKey]Rollup)
+	err := walkSlotFiles(aliasedHome, db.format.exts(), func(subFolder, fileName string) error {
+		file, err := encodeFromPath(subFolder, fileName)
+		if err != nil {
+			// not a half-day file, leave it to Verify
+			return nil
+		}
+
+		records, err := db.format.read(filepath.Join(aliasedHome, subFolder, fileName))
+		if err = db.reportCorruption(err); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		origin := file.origin(db.loc)
+		for _, r := range records {
+			addSlotRollups(acc, origin+uint32(r.offset), r.howlong, db.fixedLoc)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	home := db.rollupsHome(aliasedHome)
+	if err := os.RemoveAll(home); err != nil {
+		return err
+	}
+	db.log("rebuild rollups", zap.String("alias", filepath.Base(aliasedHome)), zap.Int("periods", len(acc)))
+	return db.addRollups(home, acc)
+}
+
+// add the rollups of "srcHome" to the ones of "dstHome"
+func (db *TDB) mergeRollups(srcHome, dstHome string) error {
+	names, err := readFolderNames(srcHome)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	acc := make(map[rollupKey]Rollup)
+	for _, name := range names {
+		period := RollupDay
+		ext := filepath.Ext(name)
+		if ext == monthExt {
+			period = RollupMonth
+		} else if ext != dayExt {
+			continue
+		}
+		year, err := strconv.Atoi(strings.TrimSuffix(name, ext))
+		if err != nil {
+			continue
+		}
+
+		rs, err := readRollupFile(filepath.Join(srcHome, name), period, year, db.fixedLoc)
+		if err != nil {
+			return err
+		}
+		for _, r := range rs {
+			key, _ := periodOf(uint64(r.Start), period, db.fixedLoc)
+			acc[key] = r
+		}
+	}
+	if err := db.addRollups(dstHome, acc); err != nil {
+		return err
+	}
+	return os.RemoveAll(srcHome)
+}
@@ -0,0 +1,213 @@
+package tdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollupEncode(t *testing.T) {
+	r := Rollup{Seconds: 1 << 40, Count: 3, First: 100, Last: 200}
+	decoded, ok := decodeRollup(encodeRollup(r))
+	assert.True(t, ok, "decode wrong")
+	assert.Equal(t, r, decoded, "rollup wrong")
+
+	decoded, ok = decodeRollup(make([]byte, rollupBytes))
+	assert.True(t, ok, "zero bytes are empty")
+	assert.True(t, decoded.empty(), "zero bytes are empty")
+
+	b := encodeRollup(r)
+	b[0]++
+	_, ok = decodeRollup(b)
+	assert.False(t, ok, "checksum wrong")
+}
+
+func TestAddSlotRollups(t *testing.T) {
+	loc := time.FixedZone("", 8*3600)
+	unix := func(month time.Month, day, hour int) uint32 {
+		return uint32(time.Date(2017, month, day, hour, 0, 0, 0, loc).Unix())
+	}
+
+	acc := make(map[rollupKey]Rollup)
+	// from 23:00 of the last day of March for two hours
+	addSlotRollups(acc, unix(3, 31, 23), 7200, loc)
+	addSlotRollups(acc, unix(4, 1, 5), 0, loc)
+
+	assert.Equal(t, Rollup{Seconds: 3600, Count: 1, First: unix(3, 31, 23), Last: unix(4, 1, 0)}, acc[rollupKey{RollupDay, 2017, 89}], "day wrong")
+	assert.Equal(t, Rollup{Seconds: 3600, Count: 1, First: unix(4, 1, 0), Last: unix(4, 1, 5)}, acc[rollupKey{RollupDay, 2017, 90}], "day wrong")
+	assert.Equal(t, Rollup{Seconds: 3600, Count: 1, First: unix(3, 31, 23), Last: unix(4, 1, 0)}, acc[rollupKey{RollupMonth, 2017, 2}], "month wrong")
+	assert.Equal(t, Rollup{Seconds: 3600, Count: 1, First: unix(4, 1, 0), Last: unix(4, 1, 5)}, acc[rollupKey{RollupMonth, 2017, 3}], "month wrong")
+	assert.Equal(t, unix(4, 1, 0), rollupKey{RollupDay, 2017, 90}.start(loc), "start wrong")
+	assert.Equal(t, unix(4, 1, 0), rollupKey{RollupMonth, 2017, 3}.start(loc), "start wrong")
+}
+
+func TestRollups(t *testing.T) {
+	folder := "test_rollups"
+	defer os.RemoveAll(folder)
+
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	assert.True(t, db.RollupsComplete(), "rollups of a new database are complete")
+
+	day := func(month time.Month, d int) uint32 {
+		return uint32(time.Date(2017, month, d, 0, 0, 0, 0, db.fixedLoc).Unix())
+	}
+	start := day(4, 2) + 3600
+	assert.NoError(t, db.AddSlot("a", start, 20), "add slot wrong")
+	assert.NoError(t, db.AddSlot("a", start+100, 30), "add slot wrong")
+	assert.NoError(t, db.AddSlot("a", start+86400, 40), "add slot wrong")
+	assert.NoError(t, db.AddSlot("b", start, 10), "add slot wrong")
+
+	expectedDays := []Rollup{
+		{Start: day(4, 2), Seconds: 50, Count: 2, First: start, Last: start + 130},
+		{Start: day(4, 3), Seconds: 40, Count: 1, First: start + 86400, Last: start + 86440},
+	}
+	rollups, err := db.Rollups("a", 0, 0, RollupDay)
+	assert.NoError(t, err, "rollups wrong")
+	assert.Equal(t, expectedDays, rollups, "days wrong")
+
+	rollups, err = db.Rollups("a", day(4, 3), 0, RollupDay)
+	assert.NoError(t, err, "rollups wrong")
+	assert.Equal(t, expectedDays[1:], rollups, "range wrong")
+
+	expectedMonth := []Rollup{{Start: day(4, 1), Seconds: 90, Count: 3, First: start, Last: start + 86440}}
+	rollups, err = db.Rollups("a", 0, 0, RollupMonth)
+	assert.NoError(t, err, "rollups wrong")
+	assert.Equal(t, expectedMonth, rollups, "month wrong")
+
+	// rebuilt the same
+	assert.NoError(t, os.RemoveAll(filepath.Join(folder, rollupsFolder)), "remove wrong")
+	assert.NoError(t, db.meta.deleteInfo([]string{rollupsKey}), "delete flag wrong")
+	assert.False(t, db.RollupsComplete(), "rollups of an old database are incomplete")
+	assert.NoError(t, db.RebuildRollups(context.Background()), "rebuild wrong")
+	assert.True(t, db.RollupsComplete(), "rollups should be complete")
+	rollups, err = db.Rollups("a", 0, 0, RollupDay)
+	assert.NoError(t, err, "rollups wrong")
+	assert.Equal(t, expectedDays, rollups, "days wrong")
+
+	// merged with the target
+	assert.NoError(t, db.MergeTargets("b", "a"), "merge wrong")
+	rollups, err = db.Rollups("a", 0, 0, RollupMonth)
+	assert.NoError(t, err, "rollups wrong")
+	assert.Equal(t, []Rollup{{Start: day(4, 1), Seconds: 100, Count: 4, First: start, Last: start + 86440}}, rollups, "merged wrong")
+
+	// removed with the target
+	home := db.rollupsHome(string(db.slot.getInfoValue("a")))
+	_, err = os.Stat(home)
+	assert.NoError(t, err, "rollups should exist")
+	assert.NoError(t, db.DeleteTarget("a"), "delete wrong")
+	_, err = os.Stat(home)
+	assert.True(t, os.IsNotExist(err), "rollups should be removed")
+	rollups, err = db.Rollups("a", 0, 0, RollupMonth)
+	assert.NoError(t, err, "rollups wrong")
+	assert.Empty(t, rollups, "no rollups")
+
+	_, err = db.Rollups("a", 0, 0, RollupPeriod(5))
+	assert.Error(t, err, "unknown period")
+}
+
+func TestRollupsOfMergeUnion(t *testing.T) {
+	src := "test_rollups_src"
+	dst := "test_rollups_dst"
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	start := uint32(1491134201)
+	createWithSlots(t, src, map[string][][2]uint32{"a": {{start + 10, 20}}})
+	createWithSlots(t, dst, map[string][][2]uint32{"a": {{start, 20}}})
+
+	db, err := Open(dst)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = Merge(db, src, MergeOptions{Policy: MergeUnion})
+	assert.NoError(t, err, "merge wrong")
+	rollups, err := db.Rollups("a", 0, 0, RollupDay)
+	assert.NoError(t, err, "rollups wrong")
+	if assert.Len(t, rollups, 1, "rollups wrong") {
+		assert.Equal(t, uint64(30), rollups[0].Seconds, "seconds of the union")
+		assert.Equal(t, uint32(1), rollups[0].Count, "slots of the union")
+	}
+}
+
+func TestRollupsIncompleteWhileWritten(t *testing.T) {
+	folder := "test_rollups_written"
+	defer os.RemoveAll(folder)
+
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := uint32(1491134201)
+	assert.NoError(t, db.AddSlot("a", start, 10), "add slot wrong")
+	assert.True(t, db.RollupsComplete(), "rollups should be complete")
+
+	// incomplete in the meantime, an interrupted write leaves them so when reopened
+	err = db.writeRollups(func() error {
+		assert.False(t, db.RollupsComplete(), "rollups should be incomplete while written")
+		return db.writeRollups(func() error {
+			assert.False(t, db.RollupsComplete(), "rollups should be incomplete while written")
+			return nil
+		})
+	})
+	assert.NoError(t, err, "write wrong")
+	assert.True(t, db.RollupsComplete(), "rollups should be complete after the writes")
+
+	// the marker is left until closed, an unclosed database has incomplete rollups when reopened
+	marker := filepath.Join(folder, rollupsMarker)
+	_, err = os.Stat(marker)
+	assert.NoError(t, err, "marker should be created")
+	assert.NoError(t, db.Close(), "close wrong")
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err), "marker should be removed")
+
+	assert.NoError(t, ioutil.WriteFile(marker, nil, 0644), "write marker wrong")
+	readOnly, err := OpenWithOptions(folder, Options{ReadOnly: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	assert.False(t, readOnly.RollupsComplete(), "rollups should be taken as incomplete")
+	assert.NoError(t, readOnly.Close(), "close wrong")
+
+	db, err = Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	assert.False(t, db.RollupsComplete(), "rollups should be incomplete")
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err), "marker should be removed")
+	assert.NoError(t, db.RebuildRollups(context.Background()), "rebuild wrong")
+	assert.True(t, db.RollupsComplete(), "rollups should be complete")
+
+	// the slot is written, but not its rollups
+	aliasedHome := filepath.Join(folder, slotsFolder, string(db.slot.getInfoValue("a")))
+	home := db.rollupsHome(aliasedHome)
+	assert.NoError(t, os.RemoveAll(home), "remove wrong")
+	assert.NoError(t, ioutil.WriteFile(home, nil, 0644), "write wrong")
+	assert.Error(t, db.AddSlot("a", start+100, 10), "rollups should fail")
+	assert.False(t, db.RollupsComplete(), "rollups should be incomplete after a failed write")
+	assert.NoError(t, db.AddSlot("b", start, 10), "add slot wrong")
+	assert.False(t, db.RollupsComplete(), "rollups should stay incomplete")
+
+	assert.NoError(t, os.Remove(home), "remove wrong")
+	assert.NoError(t, db.RebuildRollups(context.Background()), "rebuild wrong")
+	assert.True(t, db.RollupsComplete(), "rollups should be complete")
+	rollups, err := db.Rollups("a", 0, 0, RollupDay)
+	assert.NoError(t, err, "rollups wrong")
+	if assert.Len(t, rollups, 1, "rollups wrong") {
+		assert.Equal(t, uint64(20), rollups[0].Seconds, "seconds wrong")
+	}
+}
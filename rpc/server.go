@@ -0,0 +1,121 @@
+// Package rpc serves a TDB over gRPC, see tdb.proto for the service.
+// A server is registered with RegisterTDBServer(s, NewServer(db)),
+// collectors on other machines use NewTDBClient to push their actions.
+package rpc
+
+import (
+	"context"
+
+	"github.com/tracerun/tdb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements TDBServer with a database.
+type Server struct {
+	db *tdb.TDB
+}
+
+// NewServer serves "db", which is not closed by the server.
+func NewServer(db *tdb.TDB) *Server {
+	return &Server{db: db}
+}
+
+// AddAction adds an action of the target at ts.
+func (s *Server) AddAction(ctx context.Context, req *Action) (*Empty, error) {
+	if req.Target == "" {
+		return nil, status.Error(codes.InvalidArgument, "target is empty")
+	}
+	if err := s.db.AddAction(req.Target, req.Ts); err != nil {
+		return nil, toStatus(err)
+	}
+	return &Empty{}, nil
+}
+
+// GetActions gets all open actions with their start and last.
+func (s *Server) GetActions(ctx context.Context, req *Empty) (*Actions, error) {
+	targets, starts, lasts, err := s.db.GetActions()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &Actions{Actions: make([]*Action, len(targets))}
+	for i := range targets {
+		resp.Actions[i] = &Action{Target: targets[i], Start: starts[i], Last: lasts[i]}
+	}
+	return resp, nil
+}
+
+// GetTargets gets all targets having slots.
+func (s *Server) GetTargets(ctx context.Context, req *Empty) (*Targets, error) {
+	return &Targets{Targets: s.db.GetTargets()}, nil
+}
+
+// GetSlots gets the slots of a target in the range, ordered by start.
+func (s *Server) GetSlots(ctx context.Context, req *SlotRange) (*Slots, error) {
+	starts, slots, err := s.db.GetSlots(req.Target, req.Start, req.End)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &Slots{}
+	for i := range starts {
+		for j := range starts[i] {
+			resp.Slots = append(resp.Slots, &Slot{Target: req.Target, Start: starts[i][j], Howlong: slots[i][j]})
+		}
+	}
+	return resp, nil
+}
+
+// AddSlot adds a slot to the target.
+func (s *Server) AddSlot(ctx context.Context, req *Slot) (*Empty, error) {
+	if req.Target == "" {
+		return nil, status.Error(codes.InvalidArgument, "target is empty")
+	}
+	if err := s.db.AddSlot(req.Target, req.Start, req.Howlong); err != nil {
+		return nil, toStatus(err)
+	}
+	return &Empty{}, nil
+}
+
+// CheckExpirations turns the expired actions into slots.
+func (s *Server) CheckExpirations(ctx context.Context, req *Empty) (*Empty, error) {
+	if err := s.db.CheckExpirations(); err != nil {
+		return nil, toStatus(err)
+	}
+	return &Empty{}, nil
+}
+
+// GetMeta gets the metadata of the database.
+func (s *Server) GetMeta(ctx context.Context, req *Empty) (*Meta, error) {
+	m, err := s.db.Metadata()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &Meta{
+		Version:    uint32(m.Version),
+		Tag:        m.Tag,
+		CreateAt:   m.CreateAt,
+		Host:       m.Host,
+		Arch:       m.Arch,
+		Username:   m.Username,
+		Os:         m.OS,
+		ZoneOffset: m.ZoneOffset,
+		Zone:       m.Zone,
+	}, nil
+}
+
+// map the errors of the database to status codes
+func toStatus(err error) error {
+	switch err {
+	case tdb.ErrClosed:
+		return status.Error(codes.Unavailable, err.Error())
+	case tdb.ErrReadOnly:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case tdb.ErrRange, tdb.ErrActionValue:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case tdb.ErrNotExist:
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracerun/tdb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serve "db" through a buffered listener and get a client of it
+func testClient(t *testing.T, db *tdb.TDB) (TDBClient, func()) {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	RegisterTDBServer(s, NewServer(db))
+	go s.Serve(lis)
+
+	dial := func(ctx context.Context, _ string) (net.Conn, error) { return lis.Dial() }
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithContextDialer(dial), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewTDBClient(conn), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestServer(t *testing.T) {
+	folder := "test_rpc"
+	defer os.RemoveAll(folder)
+
+	db, err := tdb.Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	client, stop := testClient(t, db)
+	defer stop()
+	ctx := context.Background()
+
+	_, err = client.AddAction(ctx, &Action{Target: "a", Ts: 1491134201})
+	assert.NoError(t, err, "add action wrong")
+	_, err = client.AddAction(ctx, &Action{Target: "a", Ts: 1491134210})
+	assert.NoError(t, err, "add action wrong")
+
+	actions, err := client.GetActions(ctx, &Empty{})
+	assert.NoError(t, err, "get actions wrong")
+	if assert.Len(t, actions.Actions, 1, "actions wrong") {
+		assert.Equal(t, "a", actions.Actions[0].Target, "target wrong")
+		assert.Equal(t, uint32(1491134201), actions.Actions[0].Start, "start wrong")
+		assert.Equal(t, uint32(1491134210), actions.Actions[0].Last, "last wrong")
+	}
+
+	// the actions are long expired
+	_, err = client.CheckExpirations(ctx, &Empty{})
+	assert.NoError(t, err, "check expirations wrong")
+	actions, err = client.GetActions(ctx, &Empty{})
+	assert.NoError(t, err, "get actions wrong")
+	assert.Len(t, actions.Actions, 0, "actions should be expired")
+
+	_, err = client.AddSlot(ctx, &Slot{Target: "b", Start: 1491134300, Howlong: 30})
+	assert.NoError(t, err, "add slot wrong")
+
+	targets, err := client.GetTargets(ctx, &Empty{})
+	assert.NoError(t, err, "get targets wrong")
+	assert.Equal(t, []string{"a", "b"}, targets.Targets, "targets wrong")
+
+	slots, err := client.GetSlots(ctx, &SlotRange{Target: "a", Start: 1491134000, End: 1491135000})
+	assert.NoError(t, err, "get slots wrong")
+	if assert.Len(t, slots.Slots, 1, "slots wrong") {
+		assert.Equal(t, uint32(1491134201), slots.Slots[0].Start, "slot start wrong")
+		assert.Equal(t, uint32(9), slots.Slots[0].Howlong, "slot length wrong")
+	}
+
+	meta, err := client.GetMeta(ctx, &Empty{})
+	assert.NoError(t, err, "get meta wrong")
+	v, _ := db.Version()
+	tag, _ := db.Tag()
+	host, _ := db.Host()
+	assert.Equal(t, uint32(v), meta.Version, "version wrong")
+	assert.Equal(t, tag, meta.Tag, "tag wrong")
+	assert.Equal(t, host, meta.Host, "host wrong")
+	assert.Equal(t, "local", meta.Zone, "zone wrong")
+
+	// errors of the database
+	_, err = client.AddAction(ctx, &Action{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "empty target should be refused")
+	_, err = client.GetSlots(ctx, &SlotRange{Target: "a", Start: 10, End: 5})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "wrong range should be refused")
+
+	assert.NoError(t, db.Close(), "close wrong")
+	_, err = client.AddAction(ctx, &Action{Target: "a", Ts: 1491134201})
+	assert.Equal(t, codes.Unavailable, status.Code(err), "closed database should be unavailable")
+}
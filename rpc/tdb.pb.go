@@ -0,0 +1,794 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: rpc/tdb.proto
+
+package rpc
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Empty struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Empty) Reset()         { *m = Empty{} }
+func (m *Empty) String() string { return proto.CompactTextString(m) }
+func (*Empty) ProtoMessage()    {}
+func (*Empty) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5787468b55e399c2, []int{0}
+}
+
+func (m *Empty) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Empty.Unmarshal(m, b)
+}
+func (m *Empty) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Empty.Marshal(b, m, deterministic)
+}
+func (m *Empty) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Empty.Merge(m, src)
+}
+func (m *Empty) XXX_Size() int {
+	return xxx_messageInfo_Empty.Size(m)
+}
+func (m *Empty) XXX_DiscardUnknown() {
+	xxx_messageInfo_Empty.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Empty proto.InternalMessageInfo
+
+type Action struct {
+	Target               string   `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	Ts                   uint32   `protobuf:"varint,2,opt,name=ts,proto3" json:"ts,omitempty"`
+	Start                uint32   `protobuf:"varint,3,opt,name=start,proto3" json:"start,omitempty"`
+	Last                 uint32   `protobuf:"varint,4,opt,name=last,proto3" json:"last,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Action) Reset()         { *m = Action{} }
+func (m *Action) String() string { return proto.CompactTextString(m) }
+func (*Action) ProtoMessage()    {}
+func (*Action) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5787468b55e399c2, []int{1}
+}
+
+func (m *Action) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Action.Unmarshal(m, b)
+}
+func (m *Action) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Action.Marshal(b, m, deterministic)
+}
+func (m *Action) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Action.Merge(m, src)
+}
+func (m *Action) XXX_Size() int {
+	return xxx_messageInfo_Action.Size(m)
+}
+func (m *Action) XXX_DiscardUnknown() {
+	xxx_messageInfo_Action.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Action proto.InternalMessageInfo
+
+func (m *Action) GetTarget() string {
+	if m != nil {
+		return m.Target
+	}
+	return ""
+}
+
+func (m *Action) GetTs() uint32 {
+	if m != nil {
+		return m.Ts
+	}
+	return 0
+}
+
+func (m *Action) GetStart() uint32 {
+	if m != nil {
+		return m.Start
+	}
+	return 0
+}
+
+func (m *Action) GetLast() uint32 {
+	if m != nil {
+		return m.Last
+	}
+	return 0
+}
+
+type Actions struct {
+	Actions              []*Action `protobuf:"bytes,1,rep,name=actions,proto3" json:"actions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *Actions) Reset()         { *m = Actions{} }
+func (m *Actions) String() string { return proto.CompactTextString(m) }
+func (*Actions) ProtoMessage()    {}
+func (*Actions) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5787468b55e399c2, []int{2}
+}
+
+func (m *Actions) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Actions.Unmarshal(m, b)
+}
+func (m *Actions) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Actions.Marshal(b, m, deterministic)
+}
+func (m *Actions) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Actions.Merge(m, src)
+}
+func (m *Actions) XXX_Size() int {
+	return xxx_messageInfo_Actions.Size(m)
+}
+func (m *Actions) XXX_DiscardUnknown() {
+	xxx_messageInfo_Actions.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Actions proto.InternalMessageInfo
+
+func (m *Actions) GetActions() []*Action {
+	if m != nil {
+		return m.Actions
+	}
+	return nil
+}
+
+type Targets struct {
+	Targets              []string `protobuf:"bytes,1,rep,name=targets,proto3" json:"targets,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Targets) Reset()         { *m = Targets{} }
+func (m *Targets) String() string { return proto.CompactTextString(m) }
+func (*Targets) ProtoMessage()    {}
+func (*Targets) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5787468b55e399c2, []int{3}
+}
+
+func (m *Targets) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Targets.Unmarshal(m, b)
+}
+func (m *Targets) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Targets.Marshal(b, m, deterministic)
+}
+func (m *Targets) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Targets.Merge(m, src)
+}
+func (m *Targets) XXX_Size() int {
+	return xxx_messageInfo_Targets.Size(m)
+}
+func (m *Targets) XXX_DiscardUnknown() {
+	xxx_messageInfo_Targets.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Targets proto.InternalMessageInfo
+
+func (m *Targets) GetTargets() []string {
+	if m != nil {
+		return m.Targets
+	}
+	return nil
+}
+
+type SlotRange struct {
+	Target               string   `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	Start                uint32   `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	End                  uint32   `protobuf:"varint,3,opt,name=end,proto3" json:"end,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SlotRange) Reset()         { *m = SlotRange{} }
+func (m *SlotRange) String() string { return proto.CompactTextString(m) }
+func (*SlotRange) ProtoMessage()    {}
+func (*SlotRange) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5787468b55e399c2, []int{4}
+}
+
+func (m *SlotRange) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SlotRange.Unmarshal(m, b)
+}
+func (m *SlotRange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SlotRange.Marshal(b, m, deterministic)
+}
+func (m *SlotRange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SlotRange.Merge(m, src)
+}
+func (m *SlotRange) XXX_Size() int {
+	return xxx_messageInfo_SlotRange.Size(m)
+}
+func (m *SlotRange) XXX_DiscardUnknown() {
+	xxx_messageInfo_SlotRange.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SlotRange proto.InternalMessageInfo
+
+func (m *SlotRange) GetTarget() string {
+	if m != nil {
+		return m.Target
+	}
+	return ""
+}
+
+func (m *SlotRange) GetStart() uint32 {
+	if m != nil {
+		return m.Start
+	}
+	return 0
+}
+
+func (m *SlotRange) GetEnd() uint32 {
+	if m != nil {
+		return m.End
+	}
+	return 0
+}
+
+type Slot struct {
+	Target               string   `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	Start                uint32   `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	Howlong              uint32   `protobuf:"varint,3,opt,name=howlong,proto3" json:"howlong,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Slot) Reset()         { *m = Slot{} }
+func (m *Slot) String() string { return proto.CompactTextString(m) }
+func (*Slot) ProtoMessage()    {}
+func (*Slot) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5787468b55e399c2, []int{5}
+}
+
+func (m *Slot) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Slot.Unmarshal(m, b)
+}
+func (m *Slot) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Slot.Marshal(b, m, deterministic)
+}
+func (m *Slot) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Slot.Merge(m, src)
+}
+func (m *Slot) XXX_Size() int {
+	return xxx_messageInfo_Slot.Size(m)
+}
+func (m *Slot) XXX_DiscardUnknown() {
+	xxx_messageInfo_Slot.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Slot proto.InternalMessageInfo
+
+func (m *Slot) GetTarget() string {
+	if m != nil {
+		return m.Target
+	}
+	return ""
+}
+
+func (m *Slot) GetStart() uint32 {
+	if m != nil {
+		return m.Start
+	}
+	return 0
+}
+
+func (m *Slot) GetHowlong() uint32 {
+	if m != nil {
+		return m.Howlong
+	}
+	return 0
+}
+
+type Slots struct {
+	Slots                []*Slot  `protobuf:"bytes,1,rep,name=slots,proto3" json:"slots,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Slots) Reset()         { *m = Slots{} }
+func (m *Slots) String() string { return proto.CompactTextString(m) }
+func (*Slots) ProtoMessage()    {}
+func (*Slots) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5787468b55e399c2, []int{6}
+}
+
+func (m *Slots) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Slots.Unmarshal(m, b)
+}
+func (m *Slots) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Slots.Marshal(b, m, deterministic)
+}
+func (m *Slots) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Slots.Merge(m, src)
+}
+func (m *Slots) XXX_Size() int {
+	return xxx_messageInfo_Slots.Size(m)
+}
+func (m *Slots) XXX_DiscardUnknown() {
+	xxx_messageInfo_Slots.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Slots proto.InternalMessageInfo
+
+func (m *Slots) GetSlots() []*Slot {
+	if m != nil {
+		return m.Slots
+	}
+	return nil
+}
+
+type Meta struct {
+	Version              uint32   `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Tag                  string   `protobuf:"bytes,2,opt,name=tag,proto3" json:"tag,omitempty"`
+	CreateAt             uint32   `protobuf:"varint,3,opt,name=create_at,json=createAt,proto3" json:"create_at,omitempty"`
+	Host                 string   `protobuf:"bytes,4,opt,name=host,proto3" json:"host,omitempty"`
+	Arch                 string   `protobuf:"bytes,5,opt,name=arch,proto3" json:"arch,omitempty"`
+	Username             string   `protobuf:"bytes,6,opt,name=username,proto3" json:"username,omitempty"`
+	Os                   string   `protobuf:"bytes,7,opt,name=os,proto3" json:"os,omitempty"`
+	ZoneOffset           int32    `protobuf:"varint,8,opt,name=zone_offset,json=zoneOffset,proto3" json:"zone_offset,omitempty"`
+	Zone                 string   `protobuf:"bytes,9,opt,name=zone,proto3" json:"zone,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Meta) Reset()         { *m = Meta{} }
+func (m *Meta) String() string { return proto.CompactTextString(m) }
+func (*Meta) ProtoMessage()    {}
+func (*Meta) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5787468b55e399c2, []int{7}
+}
+
+func (m *Meta) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Meta.Unmarshal(m, b)
+}
+func (m *Meta) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Meta.Marshal(b, m, deterministic)
+}
+func (m *Meta) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Meta.Merge(m, src)
+}
+func (m *Meta) XXX_Size() int {
+	return xxx_messageInfo_Meta.Size(m)
+}
+func (m *Meta) XXX_DiscardUnknown() {
+	xxx_messageInfo_Meta.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Meta proto.InternalMessageInfo
+
+func (m *Meta) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Meta) GetTag() string {
+	if m != nil {
+		return m.Tag
+	}
+	return ""
+}
+
+func (m *Meta) GetCreateAt() uint32 {
+	if m != nil {
+		return m.CreateAt
+	}
+	return 0
+}
+
+func (m *Meta) GetHost() string {
+	if m != nil {
+		return m.Host
+	}
+	return ""
+}
+
+func (m *Meta) GetArch() string {
+	if m != nil {
+		return m.Arch
+	}
+	return ""
+}
+
+func (m *Meta) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *Meta) GetOs() string {
+	if m != nil {
+		return m.Os
+	}
+	return ""
+}
+
+func (m *Meta) GetZoneOffset() int32 {
+	if m != nil {
+		return m.ZoneOffset
+	}
+	return 0
+}
+
+func (m *Meta) GetZone() string {
+	if m != nil {
+		return m.Zone
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*Empty)(nil), "rpc.Empty")
+	proto.RegisterType((*Action)(nil), "rpc.Action")
+	proto.RegisterType((*Actions)(nil), "rpc.Actions")
+	proto.RegisterType((*Targets)(nil), "rpc.Targets")
+	proto.RegisterType((*SlotRange)(nil), "rpc.SlotRange")
+	proto.RegisterType((*Slot)(nil), "rpc.Slot")
+	proto.RegisterType((*Slots)(nil), "rpc.Slots")
+	proto.RegisterType((*Meta)(nil), "rpc.Meta")
+}
+
+func init() {
+	proto.RegisterFile("rpc/tdb.proto", fileDescriptor_5787468b55e399c2)
+}
+
+var fileDescriptor_5787468b55e399c2 = []byte{
+	// 465 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x53, 0x4d, 0x8f, 0xd3, 0x30,
+	0x10, 0x55, 0xd2, 0xa6, 0xae, 0xa7, 0x74, 0xb5, 0xb2, 0x10, 0xb2, 0xca, 0x81, 0x28, 0xc0, 0x2a,
+	0xe2, 0x50, 0x50, 0xf9, 0x05, 0x05, 0x56, 0x3d, 0x20, 0x40, 0x32, 0x7b, 0xe2, 0xb2, 0xf2, 0x26,
+	0xde, 0xb6, 0xa2, 0x1b, 0x47, 0xf6, 0xf0, 0xf9, 0x37, 0xf8, 0x73, 0xfc, 0x1c, 0xe4, 0x71, 0xd2,
+	0x0d, 0x5a, 0x71, 0xe0, 0xf6, 0xde, 0x9b, 0xc9, 0xcc, 0x73, 0xdf, 0x14, 0xe6, 0xae, 0xad, 0x9e,
+	0x63, 0x7d, 0xb5, 0x6c, 0x9d, 0x45, 0x2b, 0x46, 0xae, 0xad, 0x0a, 0x06, 0xd9, 0xf9, 0x4d, 0x8b,
+	0x3f, 0x8a, 0x4f, 0x30, 0x59, 0x57, 0xb8, 0xb7, 0x8d, 0x78, 0x00, 0x13, 0xd4, 0x6e, 0x6b, 0x50,
+	0x26, 0x79, 0x52, 0x72, 0xd5, 0x31, 0x71, 0x02, 0x29, 0x7a, 0x99, 0xe6, 0x49, 0x39, 0x57, 0x29,
+	0x7a, 0x71, 0x1f, 0x32, 0x8f, 0xda, 0xa1, 0x1c, 0x91, 0x14, 0x89, 0x10, 0x30, 0x3e, 0x68, 0x8f,
+	0x72, 0x4c, 0x22, 0xe1, 0xe2, 0x05, 0xb0, 0x38, 0xdb, 0x8b, 0xa7, 0xc0, 0x74, 0x84, 0x32, 0xc9,
+	0x47, 0xe5, 0x6c, 0x35, 0x5b, 0xba, 0xb6, 0x5a, 0xc6, 0xb2, 0xea, 0x6b, 0xc5, 0x63, 0x60, 0x17,
+	0xb4, 0xd5, 0x0b, 0x09, 0x2c, 0x1a, 0x88, 0x5f, 0x70, 0xd5, 0xd3, 0xe2, 0x2d, 0xf0, 0x8f, 0x07,
+	0x8b, 0x4a, 0x37, 0x5b, 0xf3, 0x4f, 0xd7, 0x47, 0x97, 0xe9, 0xd0, 0xe5, 0x29, 0x8c, 0x4c, 0x53,
+	0x77, 0xce, 0x03, 0x2c, 0xde, 0xc3, 0x38, 0x0c, 0xfb, 0xcf, 0x39, 0x12, 0xd8, 0xce, 0x7e, 0x3b,
+	0xd8, 0x66, 0xdb, 0xcd, 0xea, 0x69, 0x51, 0x42, 0x16, 0xe6, 0x79, 0xf1, 0x08, 0x32, 0x7f, 0xb0,
+	0x9d, 0xfb, 0xd9, 0x8a, 0xd3, 0x7b, 0xc9, 0x77, 0xd4, 0x8b, 0xdf, 0x09, 0x8c, 0xdf, 0x19, 0xd4,
+	0x61, 0xd8, 0x57, 0xe3, 0xfc, 0xde, 0x36, 0xb4, 0x7b, 0xae, 0x7a, 0x1a, 0xec, 0xa2, 0xde, 0xd2,
+	0x6a, 0xae, 0x02, 0x14, 0x0f, 0x81, 0x57, 0xce, 0x68, 0x34, 0x97, 0xba, 0x0f, 0x60, 0x1a, 0x85,
+	0x35, 0x65, 0xb0, 0xb3, 0x5d, 0x06, 0x5c, 0x11, 0x0e, 0x9a, 0x76, 0xd5, 0x4e, 0x66, 0x51, 0x0b,
+	0x58, 0x2c, 0x60, 0xfa, 0xc5, 0x1b, 0xd7, 0xe8, 0x1b, 0x23, 0x27, 0xa4, 0x1f, 0x79, 0x48, 0xdb,
+	0x7a, 0xc9, 0x48, 0x4d, 0x6d, 0x78, 0xc6, 0xec, 0xa7, 0x6d, 0xcc, 0xa5, 0xbd, 0xbe, 0xf6, 0x06,
+	0xe5, 0x34, 0x4f, 0xca, 0x4c, 0x41, 0x90, 0x3e, 0x90, 0x12, 0x16, 0x04, 0x26, 0x79, 0x5c, 0x10,
+	0xf0, 0xea, 0x57, 0x0a, 0xa3, 0x8b, 0x37, 0xaf, 0xc4, 0x13, 0xe0, 0xeb, 0xba, 0xee, 0xee, 0x6b,
+	0x98, 0xf8, 0x02, 0x88, 0xd0, 0x09, 0x8a, 0x33, 0x80, 0x8d, 0xc1, 0xfe, 0x52, 0x06, 0x95, 0xc5,
+	0xbd, 0xc1, 0x27, 0xbe, 0xeb, 0xeb, 0xef, 0xe3, 0x6e, 0x5f, 0x5f, 0x39, 0x83, 0xe9, 0xc6, 0x60,
+	0x4c, 0xe1, 0xe4, 0xf6, 0x67, 0x0f, 0xe7, 0xb2, 0x80, 0x23, 0xf7, 0x22, 0x07, 0xb6, 0xae, 0xeb,
+	0x80, 0xc5, 0x6d, 0x3a, 0x7f, 0x39, 0x7b, 0x06, 0xa7, 0xaf, 0x77, 0xa6, 0xfa, 0x7c, 0xfe, 0xbd,
+	0xdd, 0x3b, 0x7d, 0xd7, 0xdf, 0xb0, 0x37, 0x07, 0xb6, 0x31, 0x48, 0x81, 0x0e, 0x5b, 0xe2, 0xe4,
+	0x20, 0x5f, 0x4d, 0xe8, 0xff, 0xf7, 0xf2, 0xcf, 0x00, 0x4a, 0x74, 0x03, 0xa9, 0x90, 0x03, 0x00,
+	0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// TDBClient is the client API for TDB service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type TDBClient interface {
+	AddAction(ctx context.Context, in *Action, opts ...grpc.CallOption) (*Empty, error)
+	GetActions(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Actions, error)
+	GetTargets(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Targets, error)
+	GetSlots(ctx context.Context, in *SlotRange, opts ...grpc.CallOption) (*Slots, error)
+	AddSlot(ctx context.Context, in *Slot, opts ...grpc.CallOption) (*Empty, error)
+	CheckExpirations(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+	GetMeta(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Meta, error)
+}
+
+type tDBClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTDBClient(cc grpc.ClientConnInterface) TDBClient {
+	return &tDBClient{cc}
+}
+
+func (c *tDBClient) AddAction(ctx context.Context, in *Action, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/rpc.TDB/AddAction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tDBClient) GetActions(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Actions, error) {
+	out := new(Actions)
+	err := c.cc.Invoke(ctx, "/rpc.TDB/GetActions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tDBClient) GetTargets(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Targets, error) {
+	out := new(Targets)
+	err := c.cc.Invoke(ctx, "/rpc.TDB/GetTargets", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tDBClient) GetSlots(ctx context.Context, in *SlotRange, opts ...grpc.CallOption) (*Slots, error) {
+	out := new(Slots)
+	err := c.cc.Invoke(ctx, "/rpc.TDB/GetSlots", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tDBClient) AddSlot(ctx context.Context, in *Slot, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/rpc.TDB/AddSlot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tDBClient) CheckExpirations(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/rpc.TDB/CheckExpirations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tDBClient) GetMeta(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Meta, error) {
+	out := new(Meta)
+	err := c.cc.Invoke(ctx, "/rpc.TDB/GetMeta", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TDBServer is the server API for TDB service.
+type TDBServer interface {
+	AddAction(context.Context, *Action) (*Empty, error)
+	GetActions(context.Context, *Empty) (*Actions, error)
+	GetTargets(context.Context, *Empty) (*Targets, error)
+	GetSlots(context.Context, *SlotRange) (*Slots, error)
+	AddSlot(context.Context, *Slot) (*Empty, error)
+	CheckExpirations(context.Context, *Empty) (*Empty, error)
+	GetMeta(context.Context, *Empty) (*Meta, error)
+}
+
+// UnimplementedTDBServer can be embedded to have forward compatible implementations.
+type UnimplementedTDBServer struct {
+}
+
+func (*UnimplementedTDBServer) AddAction(ctx context.Context, req *Action) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddAction not implemented")
+}
+func (*UnimplementedTDBServer) GetActions(ctx context.Context, req *Empty) (*Actions, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetActions not implemented")
+}
+func (*UnimplementedTDBServer) GetTargets(ctx context.Context, req *Empty) (*Targets, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTargets not implemented")
+}
+func (*UnimplementedTDBServer) GetSlots(ctx context.Context, req *SlotRange) (*Slots, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSlots not implemented")
+}
+func (*UnimplementedTDBServer) AddSlot(ctx context.Context, req *Slot) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddSlot not implemented")
+}
+func (*UnimplementedTDBServer) CheckExpirations(ctx context.Context, req *Empty) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckExpirations not implemented")
+}
+func (*UnimplementedTDBServer) GetMeta(ctx context.Context, req *Empty) (*Meta, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMeta not implemented")
+}
+
+func RegisterTDBServer(s *grpc.Server, srv TDBServer) {
+	s.RegisterService(&_TDB_serviceDesc, srv)
+}
+
+func _TDB_AddAction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Action)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TDBServer).AddAction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TDB/AddAction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TDBServer).AddAction(ctx, req.(*Action))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TDB_GetActions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TDBServer).GetActions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TDB/GetActions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TDBServer).GetActions(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TDB_GetTargets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TDBServer).GetTargets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TDB/GetTargets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TDBServer).GetTargets(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TDB_GetSlots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SlotRange)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TDBServer).GetSlots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TDB/GetSlots",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TDBServer).GetSlots(ctx, req.(*SlotRange))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TDB_AddSlot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Slot)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TDBServer).AddSlot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TDB/AddSlot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TDBServer).AddSlot(ctx, req.(*Slot))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TDB_CheckExpirations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TDBServer).CheckExpirations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TDB/CheckExpirations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TDBServer).CheckExpirations(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TDB_GetMeta_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TDBServer).GetMeta(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TDB/GetMeta",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TDBServer).GetMeta(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _TDB_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "rpc.TDB",
+	HandlerType: (*TDBServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddAction",
+			Handler:    _TDB_AddAction_Handler,
+		},
+		{
+			MethodName: "GetActions",
+			Handler:    _TDB_GetActions_Handler,
+		},
+		{
+			MethodName: "GetTargets",
+			Handler:    _TDB_GetTargets_Handler,
+		},
+		{
+			MethodName: "GetSlots",
+			Handler:    _TDB_GetSlots_Handler,
+		},
+		{
+			MethodName: "AddSlot",
+			Handler:    _TDB_AddSlot_Handler,
+		},
+		{
+			MethodName: "CheckExpirations",
+			Handler:    _TDB_CheckExpirations_Handler,
+		},
+		{
+			MethodName: "GetMeta",
+			Handler:    _TDB_GetMeta_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "rpc/tdb.proto",
+}
@@ -1,13 +1,10 @@
 package tdb
 
 import (
-	"encoding/binary"
-	"io"
 	"math"
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 
 	"go.uber.org/zap"
 
@@ -20,11 +17,6 @@ const (
 
 	slotAliasLen = 6
 	slotsFolder  = "slots"
-	slotBytes    = 4
-	indexBytes   = 2
-
-	offsetExt = ".idx"
-	slotExt   = ".slt"
 )
 
 var (
@@ -37,36 +29,59 @@ func init() {
 
 // AddSlot to add a slot to database
 func (db *TDB) AddSlot(target string, start, howlong uint32) error {
-	aliasedHome, err := db.getAliasedHome(target)
+	if err := db.check(true); err != nil {
+		return err
+	}
+	return db.addSlot(target, start, howlong)
+}
+
+func (db *TDB) addSlot(target string, start, howlong uint32) error {
+	aliasedHome, unlock, err := db.lockAlias(target, true)
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	file := encodeFileFromUnix(start)
-	offset := start - file.origin()
-	return writeSlotToFile(aliasedHome, file, uint16(offset), howlong)
+	file := encodeFileFromUnix(start, db.loc)
+	offset := start - file.origin(db.loc)
+	return db.writeRollups(func() error {
+		err := appendSlots(db.format, aliasedHome, file, []slotRecord{{uint16(offset), howlong}}, db.opts.Sync)
+		if err = db.reportCorruption(err); err != nil {
+			return err
+		}
+		return db.rollupSlots(aliasedHome, []absRecord{{start, howlong}})
+	})
 }
 
-// GetTargets to get all the targets
+// GetTargets to get all the targets, sorted
 func (db *TDB) GetTargets() []string {
-	return db.slot.getInfoKeys()
+	return db.targets.withPrefix("")
 }
 
 // GetSlots to get slots of a target in certain range
 // return unix time and slots
 func (db *TDB) GetSlots(target string, start, end uint32) ([][]uint32, [][]uint32, error) {
-	aliasName := string(db.slot.getInfoValue(target))
-	if aliasName == "" {
-		return nil, nil, nil
+	if err := db.check(false); err != nil {
+		return nil, nil, err
+	}
+
+	aliasedHome, unlock, err := db.lockAlias(target, false)
+	if err != nil || aliasedHome == "" {
+		return nil, nil, err
 	}
+	defer unlock()
+	return db.getSlots(aliasedHome, target, start, end)
+}
+
+// get slots of a target in certain range, the caller locks the alias
+func (db *TDB) getSlots(aliasedHome, target string, start, end uint32) ([][]uint32, [][]uint32, error) {
+	aliasName := filepath.Base(aliasedHome)
 
 	files, err := db.getTargetFiles(target, start, end)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	aliasedHome := filepath.Join(db.path, slotsFolder, aliasName)
-
 	var g errgroup.Group
 	starts := make([][]uint32, len(files))
 	slots := make([][]uint32, len(files))
@@ -81,7 +96,8 @@ func (db *TDB) GetSlots(target string, start, end uint32) ([][]uint32, [][]uint3
 		i, f := i, f
 		g.Go(func() error {
 			var startsResult, slotsResult []uint32
-			thisStarts, thisSlots, err := readFile(aliasedHome, f)
+			thisStarts, thisSlots, err := readSlots(db.format, aliasedHome, f, db.loc)
+			err = db.reportCorruption(err)
 			p("one file", zap.String("alias", aliasName), zap.Any("starts", thisStarts))
 			// get the in range results
 			for i := 0; i < len(thisStarts); i++ {
@@ -98,70 +114,42 @@ func (db *TDB) GetSlots(target string, start, end uint32) ([][]uint32, [][]uint3
 	return starts, slots, err
 }
 
-func readFile(aliasedHome string, file fileEncode) ([]uint32, []uint32, error) {
-	path, fileName := file.path()
-	baseName := filepath.Join(aliasedHome, path, fileName)
-
-	idxName := strings.Join([]string{baseName, offsetExt}, "")
-	slotName := strings.Join([]string{baseName, slotExt}, "")
-
-	unlock := srcLocker.ReadLock(encodeAliasAndFile(aliasedHome, file))
-	defer unlock()
-
-	// open offset index file
-	idxFile, err := os.Open(idxName)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer idxFile.Close()
-
-	// open slot file
-	slotFile, err := os.Open(slotName)
-	if err != nil {
-		return nil, nil, err
+// load slot index from file
+func (db *TDB) loadSlotIndex() error {
+	var err error
+	if db.slot, err = createInfo(filepath.Join(db.path, slotIndex)); err != nil {
+		return err
 	}
-	defer slotFile.Close()
-
-	offsetB := make([]byte, 2)
-	slotB := make([]byte, 4)
+	db.targets = newTargetIndex(db.slot.getInfoKeys())
+	return nil
+}
 
-	origin := file.origin()
-	var starts, slots []uint32
+// lockAlias gets the aliased home folder of the target and read locks it against DeleteTarget,
+// call the returned func to unlock. If the target doesn't exist, it is created if "create" is true,
+// otherwise an empty home is returned.
+func (db *TDB) lockAlias(target string, create bool) (string, func(), error) {
 	for {
-		// read offset with 2 bytes
-		n, err := idxFile.Read(offsetB)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, nil, err
-		}
-		if n != 2 {
-			return nil, nil, err
+		var aliasedHome string
+		if create {
+			var err error
+			if aliasedHome, err = db.getAliasedHome(target); err != nil {
+				return "", nil, err
+			}
+		} else {
+			b := db.slot.getInfoValue(target)
+			if b == nil {
+				return "", func() {}, nil
+			}
+			aliasedHome = filepath.Join(db.path, slotsFolder, string(b))
 		}
-		offset := binary.LittleEndian.Uint16(offsetB)
 
-		// read slot with 4 bytes
-		n, err = slotFile.Read(slotB)
-		if err != nil {
-			return nil, nil, err
-		}
-		if n != 4 {
-			return nil, nil, err
+		unlock := srcLocker.ReadLock(aliasedHome)
+		// the target may be deleted before locked
+		if b := db.slot.getInfoValue(target); b != nil && filepath.Join(db.path, slotsFolder, string(b)) == aliasedHome {
+			return aliasedHome, unlock, nil
 		}
-		slot := binary.LittleEndian.Uint32(slotB)
-
-		starts = append(starts, origin+uint32(offset))
-		slots = append(slots, slot)
+		unlock()
 	}
-	return starts, slots, nil
-}
-
-// load slot index from file
-func (db *TDB) loadSlotIndex() error {
-	var err error
-	db.slot, err = createInfo(filepath.Join(db.path, slotIndex))
-	return err
 }
 
 // get aliased home folder for the target
@@ -203,57 +191,41 @@ func (db *TDB) getAliasedHome(target string) (string, error) {
 			break
 		}
 	}
-	return aliasedHome, db.slot.updateInfo([]string{target}, [][]byte{[]byte(aliasName)})
-}
-
-func writeSlotToFile(aliasedHome string, file fileEncode, offset uint16, howlong uint32) error {
-	subFolder, fileName := file.path()
-
-	fullFolder := filepath.Join(aliasedHome, subFolder)
-	// create folder if not exist
-	if err := createFolder(fullFolder); err != nil {
-		return err
-	}
-
-	unlock := srcLocker.WriteLock(encodeAliasAndFile(aliasedHome, file))
-	defer unlock()
-
-	// append to offset file
-	offsetFileName := strings.Join([]string{fileName, offsetExt}, "")
-	offsetB := make([]byte, 2)
-	binary.LittleEndian.PutUint16(offsetB, offset)
-	if err := appendToFile(filepath.Join(fullFolder, offsetFileName), offsetB); err != nil {
-		return err
+	if err := db.slot.updateInfo([]string{target}, [][]byte{[]byte(aliasName)}); err != nil {
+		return "", err
 	}
-
-	// append to slot file
-	slotFileName := strings.Join([]string{fileName, slotExt}, "")
-	slotB := make([]byte, 4)
-	binary.LittleEndian.PutUint32(slotB, howlong)
-	return appendToFile(filepath.Join(fullFolder, slotFileName), slotB)
+	db.targets.add(target)
+	return aliasedHome, nil
 }
 
-func appendToFile(fullPath string, b []byte) error {
+// append bytes to the file, fsync it before return if "sync" is true.
+func appendToFile(fullPath string, b []byte, sync bool) error {
 	f, err := os.OpenFile(fullPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	_, err = f.Write(b)
-	return err
+	if _, err = f.Write(b); err != nil {
+		return err
+	}
+	if sync {
+		return f.Sync()
+	}
+	return nil
 }
 
 // Only get target files that contain slots between start and end.
 // start, end should be unixtime
 func (db *TDB) getTargetFiles(target string, start, end uint32) ([]fileEncode, error) {
-	aliasedHome, err := db.getAliasedHome(target)
-	if err != nil {
-		return nil, err
+	aliasName := string(db.slot.getInfoValue(target))
+	if aliasName == "" {
+		return nil, nil
 	}
+	aliasedHome := filepath.Join(db.path, slotsFolder, aliasName)
 
 	// create the range
-	fRange, err := newFileRange(start, end)
+	fRange, err := newFileRange(start, end, db.loc)
 	if err != nil {
 		return nil, err
 	}
@@ -266,7 +238,7 @@ func (db *TDB) getTargetFiles(target string, start, end uint32) ([]fileEncode, e
 
 	var fileEncodes []fileEncode
 	for _, folder := range folders {
-		files, err := getInRangeFiles(fRange, aliasedHome, folder)
+		files, err := getInRangeFiles(fRange, aliasedHome, folder, db.format.exts()[0])
 		if err != nil {
 			return nil, err
 		}
@@ -308,8 +280,8 @@ func getInRangeFolders(fRange *fileRange, aliasedHome string) ([]string, error)
 	return fs, err
 }
 
-// get sorted []fileEncode
-func getInRangeFiles(fRange *fileRange, aliasedHome, subFolder string) ([]fileEncode, error) {
+// get sorted []fileEncode, the half-day files are listed by "ext"
+func getInRangeFiles(fRange *fileRange, aliasedHome, subFolder, ext string) ([]fileEncode, error) {
 	f, err := os.Open(filepath.Join(aliasedHome, subFolder))
 	if err != nil {
 		return nil, err
@@ -324,7 +296,7 @@ func getInRangeFiles(fRange *fileRange, aliasedHome, subFolder string) ([]fileEn
 
 	for _, n := range names {
 		// check whether this file is in range
-		if filepath.Ext(n) != offsetExt {
+		if filepath.Ext(n) != ext {
 			continue
 		}
 
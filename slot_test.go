@@ -13,7 +13,10 @@ func TestWriteSlotToFile(t *testing.T) {
 	file := encodeFile(2017, 4, 11, 23)
 	defer os.RemoveAll(folder)
 
-	err := writeSlotToFile(folder, file, uint16(123), uint32(123))
+	err := appendSlots(formatV1{}, folder, file, []slotRecord{{uint16(123), uint32(123)}}, false)
+	assert.NoError(t, err, "append to file wrong")
+
+	err = appendSlots(formatV2{}, folder, file, []slotRecord{{uint16(123), uint32(123)}}, false)
 	assert.NoError(t, err, "append to file wrong")
 }
 
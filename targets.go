@@ -0,0 +1,123 @@
+package tdb
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// TargetStat is where the slots of a target are stored.
+type TargetStat struct {
+	Target string `json:"target"`
+	Alias  string `json:"alias"` // folder of the target under "slots"
+	Files  int    `json:"files"` // half-day slot files
+}
+
+// TargetStats gets the storage of every target, ordered by target.
+func (db *TDB) TargetStats() ([]TargetStat, error) {
+	if err := db.check(false); err != nil {
+		return nil, err
+	}
+
+	var stats []TargetStat
+	for _, target := range db.GetTargets() {
+		aliasedHome, unlock, err := db.lockAlias(target, false)
+		if err != nil {
+			return nil, err
+		}
+		if aliasedHome == "" {
+			// deleted meanwhile
+			continue
+		}
+		files, err := countSlotFiles(aliasedHome, db.format.exts()[0])
+		unlock()
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, TargetStat{target, filepath.Base(aliasedHome), files})
+	}
+	return stats, nil
+}
+
+// count the half-day files listed by "ext" in the year-month folders
+func countSlotFiles(aliasedHome, ext string) (int, error) {
+	folders, err := readFolderNames(aliasedHome)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, folder := range folders {
+		if !validFolderName(folder) {
+			continue
+		}
+		names, err := readFolderNames(filepath.Join(aliasedHome, folder))
+		if err != nil {
+			return 0, err
+		}
+		for _, name := range names {
+			if filepath.Ext(name) == ext {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// targetIndex keeps the target names sorted for prefix lookups.
+type targetIndex struct {
+	lock  sync.RWMutex
+	names []string
+}
+
+func newTargetIndex(names []string) *targetIndex {
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+	sort.Strings(sorted)
+	return &targetIndex{names: sorted}
+}
+
+// add a name if not exist
+func (ti *targetIndex) add(name string) {
+	ti.lock.Lock()
+	defer ti.lock.Unlock()
+
+	i := sort.SearchStrings(ti.names, name)
+	if i < len(ti.names) && ti.names[i] == name {
+		return
+	}
+	ti.names = append(ti.names, "")
+	copy(ti.names[i+1:], ti.names[i:])
+	ti.names[i] = name
+}
+
+// remove a name if exist
+func (ti *targetIndex) remove(name string) {
+	ti.lock.Lock()
+	defer ti.lock.Unlock()
+
+	i := sort.SearchStrings(ti.names, name)
+	if i < len(ti.names) && ti.names[i] == name {
+		ti.names = append(ti.names[:i], ti.names[i+1:]...)
+	}
+}
+
+// get the sorted names with the prefix, all names if it is empty
+func (ti *targetIndex) withPrefix(prefix string) []string {
+	ti.lock.RLock()
+	defer ti.lock.RUnlock()
+
+	from := sort.SearchStrings(ti.names, prefix)
+	to := from
+	for to < len(ti.names) && len(ti.names[to]) >= len(prefix) && ti.names[to][:len(prefix)] == prefix {
+		to++
+	}
+
+	names := make([]string, to-from)
+	copy(names, ti.names[from:to])
+	return names
+}
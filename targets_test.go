@@ -0,0 +1,51 @@
+package tdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargetIndex(t *testing.T) {
+	ti := newTargetIndex([]string{"/proj/b", "/home", "/proj/a"})
+	assert.Equal(t, []string{"/home", "/proj/a", "/proj/b"}, ti.withPrefix(""), "should be sorted")
+
+	ti.add("/proj/c")
+	ti.add("/proj/a")
+	ti.add("/a")
+	assert.Equal(t, []string{"/a", "/home", "/proj/a", "/proj/b", "/proj/c"}, ti.withPrefix(""), "add wrong")
+	assert.Equal(t, []string{"/proj/a", "/proj/b", "/proj/c"}, ti.withPrefix("/proj/"), "prefix wrong")
+	assert.Empty(t, ti.withPrefix("/x"), "no match")
+
+	ti.remove("/proj/b")
+	ti.remove("/none")
+	assert.Equal(t, []string{"/proj/a", "/proj/c"}, ti.withPrefix("/proj"), "remove wrong")
+}
+
+func TestTargetStats(t *testing.T) {
+	folder := "test_target_stats"
+	defer os.RemoveAll(folder)
+
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	assert.NoError(t, db.AddSlot("b", 1491134201, 20), "add slot wrong")
+	assert.NoError(t, db.AddSlot("b", 1491134201+halfDaySeconds, 20), "add slot wrong")
+	assert.NoError(t, db.AddSlot("a", 1491134201, 20), "add slot wrong")
+
+	stats, err := db.TargetStats()
+	assert.NoError(t, err, "stats wrong")
+	if assert.Len(t, stats, 2, "stats wrong") {
+		assert.Equal(t, "a", stats[0].Target, "should be ordered by target")
+		assert.Equal(t, 1, stats[0].Files, "files of a wrong")
+		assert.Equal(t, "b", stats[1].Target, "should be ordered by target")
+		assert.Equal(t, 2, stats[1].Files, "files of b wrong")
+		_, err := os.Stat(filepath.Join(folder, slotsFolder, stats[1].Alias))
+		assert.NoError(t, err, "alias wrong")
+	}
+}
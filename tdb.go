@@ -1,27 +1,177 @@
 package tdb
 
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/drkaka/lg"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Options to open a TDB.
+type Options struct {
+	// ActionExpiry is the idle gap in seconds after which an action turns into a slot.
+	// It is persisted in metadata, 0 keeps the persisted value or the default of 15 seconds.
+	ActionExpiry uint32
+
+	// ExpiryRules overrides ActionExpiry for targets with certain prefixes.
+	// They are persisted in metadata and replace the persisted ones if not nil.
+	ExpiryRules []ExpiryRule
+
+	// Sync makes every write fsync the file before returning.
+	Sync bool
+
+	// ActionLogLimit is the number of action journal records before compacting them
+	// into the action snapshot. 0 means 1024.
+	ActionLogLimit int
+
+	// Logger used for debug output, the lg logger is used if nil.
+	Logger *zap.Logger
+
+	// ReadOnly opens an existing database without creating or changing anything.
+	ReadOnly bool
+
+	// MustExist returns ErrNotExist instead of creating a database if the folder holds none.
+	MustExist bool
+
+	// KeepActions makes Close keep the open actions instead of expiring the due ones.
+	KeepActions bool
+
+	// Version is the on-disk format version of a new database, 0 means the latest.
+	// Existing databases keep the version in their metadata.
+	Version uint8
+
+	// Zone buckets the half-day slot files of a new database, ZoneLocal by default.
+	// Existing databases keep the zone in their metadata, use Rebucket to change it.
+	Zone Zone
+
+	// AutoMigrate migrates an existing database of an older version to the latest one on open.
+	// Older versions are still readable without it, newer ones are refused.
+	AutoMigrate bool
+
+	// Repair checks all slot files on open and truncates torn ones to the last
+	// consistent record, each of them is reported through OnError.
+	Repair bool
+
+	// ExpireInterval starts a background worker checking expired actions at this interval.
+	// 0 disables the worker, CheckExpirations has to be called then.
+	ExpireInterval time.Duration
+
+	// Retention keeps the slots for this long, older half-day files are removed by Prune.
+	// 0 keeps them forever.
+	Retention time.Duration
+
+	// RetentionRules sets the retention of targets by prefix, overriding Retention.
+	RetentionRules []RetentionRule
+
+	// RollupRetention keeps the rollups for this long by whole years, they are removed by Prune.
+	// 0 keeps them forever.
+	RollupRetention time.Duration
+
+	// PruneInterval starts a background worker calling Prune at this interval, 0 disables it.
+	PruneInterval time.Duration
+
+	// OnError receives errors from background workers and corrupted files found
+	// while reading or writing, they are logged as warnings if nil.
+	OnError func(error)
+
+	// Clock used for timers, the wall clock is used if nil.
+	Clock Clock
+}
+
 // TDB is the entrance point.
 type TDB struct {
-	path string // the folder path used for tdb
+	path   string // the folder path used for tdb
+	opts   Options
+	clock  Clock
+	format slotFormat     // layout of slot files, by version
+	loc    *time.Location // location to bucket slot files, by zone
+
+	fixedLoc *time.Location // location of the zone offset, for days of rollups
+
+	slot      *info        // slot index info
+	targets   *targetIndex // sorted targets of the slot index
+	meta      *info        // meta info
+	action    *info        // record actions
+	actionLog *journal     // changes of actions since the snapshot
+
+	expiry      uint32       // default action expiry
+	expiryRules []ExpiryRule // sorted with the longest prefix first
 
-	slot   *info // slot index info
-	meta   *info // meta info
-	action *info // record actions
+	retentionRules []RetentionRule // sorted with the longest prefix first
+
+	rollupLock    sync.Mutex // guards the rollups state while slots and rollups are written
+	rollupWriters int        // writes of slots and rollups in flight
+	rollupsMarked bool       // the dirty marker is created for the writes since opened
+	rollupsFailed bool       // a write failed since opened or rebuilt
+	rollupsBroken bool       // read-only with the dirty marker of another writer
+
+	closed  int32          // set to 1 once Close is called
+	closing chan struct{}  // closed to stop background workers
+	workers sync.WaitGroup // background workers
 }
 
-// Open creates an instance of TDB.
+// Open creates an instance of TDB with default options.
 // "p" should be a path for folder, if not exist, create one.
 func Open(p string) (*TDB, error) {
-	db := &TDB{path: p}
-	if err := createFolder(p); err != nil {
+	return OpenWithOptions(p, Options{})
+}
+
+// OpenWithOptions creates an instance of TDB with the given options.
+// "p" should be a path for folder, if not exist, create one unless read-only or MustExist.
+func OpenWithOptions(p string, opts Options) (*TDB, error) {
+	db := &TDB{
+		path:    p,
+		opts:    opts,
+		clock:   opts.Clock,
+		closing: make(chan struct{}),
+	}
+	if db.clock == nil {
+		db.clock = wallClock{}
+	}
+
+	if opts.ReadOnly || opts.MustExist {
+		exist, err := checkFolderExist(p)
+		if err != nil {
+			return nil, err
+		}
+		if !exist {
+			return nil, ErrNotExist
+		}
+	} else if err := createFolder(p); err != nil {
 		return nil, err
 	}
+	if opts.MustExist {
+		if _, err := os.Stat(filepath.Join(p, metafile)); os.IsNotExist(err) {
+			return nil, ErrNotExist
+		}
+	}
+
+	if opts.AutoMigrate && !opts.ReadOnly {
+		if err := Migrate(p, version); err != nil {
+			return nil, err
+		}
+	}
 
 	// load meta information
 	if err := db.loadMeta(); err != nil {
 		return nil, err
 	}
 
+	if err := db.checkRollupsMarker(); err != nil {
+		return nil, err
+	}
+
+	// load action expiry settings
+	if err := db.loadExpiry(); err != nil {
+		return nil, err
+	}
+	db.loadRetention()
+
 	// load slot index
 	if err := db.loadSlotIndex(); err != nil {
 		return nil, err
@@ -32,5 +182,84 @@ func Open(p string) (*TDB, error) {
 		return nil, err
 	}
 
+	if opts.Repair && !opts.ReadOnly {
+		if err := db.repairSlots(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.ExpireInterval > 0 && !opts.ReadOnly {
+		db.startWorker("expiration", opts.ExpireInterval, db.checkExpirations)
+	}
+	if opts.PruneInterval > 0 && !opts.ReadOnly {
+		db.startWorker("prune", opts.PruneInterval, func() error {
+			ctx, cancel := db.closingContext()
+			defer cancel()
+			_, err := db.prune(ctx)
+			return err
+		})
+	}
+
 	return db, nil
 }
+
+// Close expires due actions unless KeepActions, flushes the action index and stops background workers.
+// Any call after Close returns ErrClosed.
+func (db *TDB) Close() error {
+	if !atomic.CompareAndSwapInt32(&db.closed, 0, 1) {
+		return ErrClosed
+	}
+	close(db.closing)
+	db.workers.Wait()
+
+	if db.opts.ReadOnly {
+		return nil
+	}
+
+	if !db.opts.KeepActions {
+		if err := db.checkExpirations(); err != nil {
+			db.actionLog.close()
+			return err
+		}
+	}
+	if err := db.compactActions(); err != nil {
+		db.actionLog.close()
+		return err
+	}
+	if err := db.removeRollupsMarker(); err != nil {
+		db.actionLog.close()
+		return err
+	}
+	return db.actionLog.close()
+}
+
+// check whether the database can be used, and written to if "write" is true.
+func (db *TDB) check(write bool) error {
+	if atomic.LoadInt32(&db.closed) == 1 {
+		return ErrClosed
+	}
+	if write && db.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// print debug information with the configured logger.
+func (db *TDB) log(msg string, fields ...zapcore.Field) {
+	if db.opts.Logger != nil {
+		db.opts.Logger.Debug(msg, fields...)
+		return
+	}
+	p(msg, fields...)
+}
+
+// print a warning with the configured logger, it is not hidden at the debug level.
+func (db *TDB) warn(msg string, fields ...zapcore.Field) {
+	logger := db.opts.Logger
+	if logger == nil {
+		logger = lg.L(nil)
+	}
+	if logger != nil {
+		logger.Warn(msg, fields...)
+	}
+}
@@ -0,0 +1,126 @@
+package tdb
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenWithOptions(t *testing.T) {
+	folder := "test_open_options"
+	defer os.RemoveAll(folder)
+
+	// read-only on a missing folder
+	_, err := OpenWithOptions(folder, Options{ReadOnly: true})
+	assert.Equal(t, ErrNotExist, err, "read-only should not create the folder")
+	_, err = OpenWithOptions(folder, Options{MustExist: true})
+	assert.Equal(t, ErrNotExist, err, "must-exist should not create the folder")
+	_, err = os.Stat(folder)
+	assert.True(t, os.IsNotExist(err), "folder should not be created")
+
+	// must-exist on a folder without a database
+	assert.NoError(t, os.Mkdir(folder, 0755), "create folder wrong")
+	_, err = OpenWithOptions(folder, Options{MustExist: true})
+	assert.Equal(t, ErrNotExist, err, "must-exist should not create a database")
+
+	db, err := OpenWithOptions(folder, Options{ActionExpiry: 60, Sync: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	target := string(randBytes(6))
+	assert.Equal(t, uint32(60), db.expiryFor(target), "action expiry wrong")
+
+	now := uint32(time.Now().Unix())
+	assert.NoError(t, db.AddAction(target, now), "error add an action")
+	// within 60 seconds, should not create a slot
+	assert.NoError(t, db.AddAction(target, now+30), "error add an action")
+
+	starts, _, err := db.GetSlots(target, 0, 0)
+	assert.NoError(t, err, "get slots wrong")
+	assert.Len(t, starts, 0, "should have no slot")
+	assert.NoError(t, db.Close(), "error closing")
+
+	ro, err := OpenWithOptions(folder, Options{ReadOnly: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+
+	targets, _, _, err := ro.GetActions()
+	assert.NoError(t, err, "get all actions wrong")
+	assert.Equal(t, []string{target}, targets, "action should be flushed")
+
+	assert.Equal(t, ErrReadOnly, ro.AddAction(target, now), "should not write when read-only")
+	assert.Equal(t, ErrReadOnly, ro.AddSlot(target, now, 1), "should not write when read-only")
+	assert.Equal(t, ErrReadOnly, ro.CheckExpirations(), "should not write when read-only")
+}
+
+func TestClose(t *testing.T) {
+	folder := "test_close"
+	defer os.RemoveAll(folder)
+
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+
+	// an expired action turns into a slot while closing
+	target := string(randBytes(6))
+	now := uint32(time.Now().Unix())
+	assert.NoError(t, db.AddAction(target, now-actionExp-2), "error add an action")
+
+	assert.NoError(t, db.Close(), "error closing")
+	assert.Equal(t, ErrClosed, db.Close(), "close twice")
+
+	assert.Equal(t, ErrClosed, db.AddAction(target, now), "should be closed")
+	assert.Equal(t, ErrClosed, db.AddSlot(target, now, 1), "should be closed")
+	assert.Equal(t, ErrClosed, db.CheckExpirations(), "should be closed")
+	_, _, _, err = db.GetActions()
+	assert.Equal(t, ErrClosed, err, "should be closed")
+	_, _, err = db.GetSlots(target, 0, 0)
+	assert.Equal(t, ErrClosed, err, "should be closed")
+
+	db, err = Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	targets, _, _, err := db.GetActions()
+	assert.NoError(t, err, "get all actions wrong")
+	assert.Len(t, targets, 0, "action should be expired")
+
+	starts, slots, err := db.GetSlots(target, 0, 0)
+	assert.NoError(t, err, "get slots wrong")
+	if assert.Len(t, starts, 1, "should have one file") {
+		assert.Equal(t, []uint32{now - actionExp - 2}, starts[0], "start wrong")
+		assert.Equal(t, []uint32{1}, slots[0], "slot wrong")
+	}
+}
+
+func TestKeepActions(t *testing.T) {
+	folder := "test_keep_actions"
+	defer os.RemoveAll(folder)
+
+	db, err := OpenWithOptions(folder, Options{KeepActions: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	target := string(randBytes(6))
+	now := uint32(time.Now().Unix())
+	assert.NoError(t, db.AddAction(target, now-actionExp-2), "error add an action")
+
+	// the expired action is kept while closing
+	assert.NoError(t, db.Close(), "error closing")
+
+	db, err = OpenWithOptions(folder, Options{ReadOnly: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	targets, _, _, err := db.GetActions()
+	assert.NoError(t, err, "get all actions wrong")
+	assert.Equal(t, []string{target}, targets, "action should be kept")
+}
@@ -0,0 +1,68 @@
+package tdb
+
+import (
+	"context"
+	"math"
+)
+
+// Span is a period of [Start, End) in unix time.
+type Span struct {
+	Start uint32
+	End   uint32
+}
+
+// Timeline is the busy time of several targets.
+type Timeline struct {
+	Spans []Span // the union of the slots, ordered and not overlapping
+	Busy  uint64 // total seconds of the spans
+}
+
+// Timeline returns the union of the slots of the targets starting between "start" and "end",
+// all targets if "targets" is empty, 0 "end" means no end. Time active in several targets
+// is counted once, the seconds after "end" are not counted.
+func (db *TDB) Timeline(ctx context.Context, targets []string, start, end uint32) (Timeline, error) {
+	var tl Timeline
+	if err := db.check(false); err != nil {
+		return tl, err
+	}
+	if end != 0 && end < start {
+		return tl, ErrRange
+	}
+	if len(targets) == 0 {
+		targets = db.GetTargets()
+	}
+
+	limit := uint64(math.MaxUint32)
+	if end != 0 {
+		limit = uint64(end) + 1
+	}
+
+	q := db.queryTargets(ctx, targets, start, end, IterOptions{})
+	for q.Next() {
+		slot := q.Slot()
+		to := uint64(slot.Start) + uint64(slot.Duration)
+		if to > limit {
+			to = limit
+		}
+		if to <= uint64(slot.Start) {
+			continue
+		}
+
+		// slots come ordered by start, only the last span can be extended
+		if l := len(tl.Spans) - 1; l >= 0 && slot.Start <= tl.Spans[l].End {
+			if uint32(to) > tl.Spans[l].End {
+				tl.Spans[l].End = uint32(to)
+			}
+			continue
+		}
+		tl.Spans = append(tl.Spans, Span{slot.Start, uint32(to)})
+	}
+	if err := q.Err(); err != nil {
+		return tl, err
+	}
+
+	for _, span := range tl.Spans {
+		tl.Busy += uint64(span.End - span.Start)
+	}
+	return tl, nil
+}
@@ -0,0 +1,42 @@
+package tdb
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeline(t *testing.T) {
+	folder := "test_timeline"
+	defer os.RemoveAll(folder)
+
+	start := uint32(1491134201)
+	createWithSlots(t, folder, map[string][][2]uint32{
+		"a": {{start, 100}, {start + 300, 50}, {start + 500, 0}},
+		"b": {{start + 50, 100}, {start + 350, 10}},
+		"c": {{start + 1000, 20}},
+	})
+
+	db, err := OpenWithOptions(folder, Options{ReadOnly: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	tl, err := db.Timeline(ctx, []string{"a", "b"}, 0, 0)
+	assert.NoError(t, err, "timeline wrong")
+	// adjacent spans are joined
+	assert.Equal(t, []Span{{start, start + 150}, {start + 300, start + 360}}, tl.Spans, "spans wrong")
+	assert.Equal(t, uint64(210), tl.Busy, "busy wrong")
+
+	tl, err = db.Timeline(ctx, nil, start+10, start+1009)
+	assert.NoError(t, err, "timeline wrong")
+	assert.Equal(t, []Span{{start + 50, start + 150}, {start + 300, start + 360}, {start + 1000, start + 1010}}, tl.Spans, "range wrong")
+	assert.Equal(t, uint64(170), tl.Busy, "busy wrong")
+
+	_, err = db.Timeline(ctx, nil, 10, 5)
+	assert.Equal(t, ErrRange, err, "range wrong")
+}
@@ -1,6 +1,7 @@
 package tdb
 
 import (
+	"fmt"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -13,6 +14,9 @@ import (
 const (
 	letterBytes = "0123456789abcdefghijklmnopqrstuvwxyz"
 	letterLen   = 36
+
+	tmpExt  = ".tmp"  // temporary file of an atomic write
+	prevExt = ".prev" // previous generation kept by an atomic write
 )
 
 func randBytes(n int) []byte {
@@ -51,6 +55,65 @@ func createFolder(folder string) error {
 	return err
 }
 
+// writeFile writes bytes to the file, fsync it before return if "sync" is true.
+func writeFile(fullPath string, b []byte, sync bool) error {
+	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(b); err == nil && sync {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeFileAtomic replaces the file through a fsynced temporary file and a rename,
+// the replaced one is kept as the previous generation with prevExt.
+func writeFileAtomic(fullPath string, b []byte) error {
+	tmpPath := fullPath + tmpExt
+	if err := writeFile(tmpPath, b, true); err != nil {
+		return err
+	}
+
+	if err := os.Rename(fullPath, fullPath+prevExt); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		return err
+	}
+	return syncFolder(filepath.Dir(fullPath))
+}
+
+// replaceFile replaces the file through a fsynced temporary file and a rename.
+func replaceFile(fullPath string, b []byte) error {
+	tmpPath := fullPath + tmpExt
+	if err := writeFile(tmpPath, b, true); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		return err
+	}
+	return syncFolder(filepath.Dir(fullPath))
+}
+
+// syncFolder to fsync a folder so that renames in it are durable.
+func syncFolder(folder string) error {
+	f, err := os.Open(folder)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// some platforms can't sync a folder, renames are still done
+	f.Sync()
+	return nil
+}
+
 // get the file name without ext and path
 func getFileName(fPath string) string {
 	baseName := filepath.Base(fPath)
@@ -62,3 +125,17 @@ func p(msg string, fields ...zapcore.Field) {
 		lg.L(nil).Debug(msg, fields...)
 	}
 }
+
+// get the path "<folder>.<name>.bak" next to the absolute folder,
+// an error is returned if it would be inside the folder, like for the root
+func backupPath(folder, name string) (string, error) {
+	abs, err := filepath.Abs(folder)
+	if err != nil {
+		return "", err
+	}
+	backup := abs + "." + name + ".bak"
+	if rel, err := filepath.Rel(abs, backup); err != nil || !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("backup %s would be inside the database folder %s", backup, abs)
+	}
+	return backup, nil
+}
@@ -60,3 +60,22 @@ func TestGetFileName(t *testing.T) {
 	fPath := filepath.Join("201702", "050.idx")
 	assert.Equal(t, "050", getFileName(fPath), "file name wrong")
 }
+
+func TestBackupPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+
+	backup, err := backupPath("db", "v1")
+	assert.NoError(t, err, "backup path wrong")
+	assert.Equal(t, filepath.Join(wd, "db")+".v1.bak", backup, "backup path wrong")
+
+	// next to the current folder, not inside it
+	backup, err = backupPath(".", "v1")
+	assert.NoError(t, err, "backup path wrong")
+	assert.Equal(t, wd+".v1.bak", backup, "backup path wrong")
+
+	_, err = backupPath(string(filepath.Separator), "v1")
+	assert.Error(t, err, "backup of the root should fail")
+}
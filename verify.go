@@ -0,0 +1,319 @@
+package tdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const halfDaySeconds = 43200
+
+// ProblemKind is the kind of a problem found by Verify.
+type ProblemKind string
+
+// Kinds of problems found by Verify.
+const (
+	ProblemMissingAlias ProblemKind = "missing-alias" // alias folder of a target doesn't exist
+	ProblemOrphanAlias  ProblemKind = "orphan-alias"  // alias folder not used by any target
+	ProblemBadFolder    ProblemKind = "bad-folder"    // not a year-month folder
+	ProblemBadFile      ProblemKind = "bad-file"      // not a half-day file
+	ProblemTornFile     ProblemKind = "torn-file"     // a record is partially written
+	ProblemChecksum     ProblemKind = "checksum"      // records failing the checksum
+	ProblemBadOffset    ProblemKind = "bad-offset"    // offset out of the half-day
+	ProblemBadAction    ProblemKind = "bad-action"    // action value can't be decoded
+)
+
+// Problem found by Verify.
+type Problem struct {
+	Kind     ProblemKind
+	Target   string // the target, if known
+	Path     string // the file or folder, if any
+	Detail   string
+	Repaired bool
+}
+
+func (pr Problem) String() string {
+	var parts []string
+	parts = append(parts, string(pr.Kind))
+	if pr.Target != "" {
+		parts = append(parts, fmt.Sprintf("target=%q", pr.Target))
+	}
+	if pr.Path != "" {
+		parts = append(parts, fmt.Sprintf("path=%s", pr.Path))
+	}
+	if pr.Detail != "" {
+		parts = append(parts, pr.Detail)
+	}
+	if pr.Repaired {
+		parts = append(parts, "(repaired)")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Report of Verify.
+type Report struct {
+	Targets  int // targets checked
+	Files    int // half-day files checked
+	Problems []Problem
+}
+
+// OK reports whether no unrepaired problem was found.
+func (r *Report) OK() bool {
+	for _, pr := range r.Problems {
+		if !pr.Repaired {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) add(pr Problem) {
+	r.Problems = append(r.Problems, pr)
+}
+
+// Verify checks the whole database folder without changing it.
+func (db *TDB) Verify(ctx context.Context) (Report, error) {
+	if err := db.check(false); err != nil {
+		return Report{}, err
+	}
+	return db.verify(ctx, false)
+}
+
+// Repair checks the whole database folder like Verify and fixes what can be fixed safely:
+// missing alias folders are created, torn files are truncated and bad actions are dropped.
+// Orphan folders, bad names and bad offsets are only reported.
+func (db *TDB) Repair(ctx context.Context) (Report, error) {
+	if err := db.check(true); err != nil {
+		return Report{}, err
+	}
+	return db.verify(ctx, true)
+}
+
+func (db *TDB) verify(ctx context.Context, repair bool) (Report, error) {
+	var r Report
+	slotsHome := filepath.Join(db.path, slotsFolder)
+
+	// every alias in the index should have a folder
+	targets, values := db.slot.getAllInfo()
+	aliases := make(map[string]string)
+	for i, target := range targets {
+		alias := string(values[i])
+		aliases[alias] = target
+		r.Targets++
+
+		aliasedHome := filepath.Join(slotsHome, alias)
+		exist, err := checkFolderExist(aliasedHome)
+		if err != nil && err != ErrPathNotFolder {
+			return r, err
+		}
+		if exist {
+			continue
+		}
+
+		pr := Problem{Kind: ProblemMissingAlias, Target: target, Path: aliasedHome}
+		if repair && err == nil {
+			if err := createFolder(aliasedHome); err != nil {
+				return r, err
+			}
+			pr.Repaired = true
+		}
+		r.add(pr)
+	}
+
+	// every folder under slots should be an alias in use
+	names, err := readFolderNames(slotsHome)
+	if err != nil && !os.IsNotExist(err) {
+		return r, err
+	}
+	sort.Strings(names)
+	for _, alias := range names {
+		if err := ctx.Err(); err != nil {
+			return r, err
+		}
+
+		aliasedHome := filepath.Join(slotsHome, alias)
+		target, ok := aliases[alias]
+		if !ok {
+			r.add(Problem{Kind: ProblemOrphanAlias, Path: aliasedHome})
+			continue
+		}
+		unlock := srcLocker.ReadLock(aliasedHome)
+		err := db.verifyAlias(aliasedHome, target, repair, &r)
+		unlock()
+		if err != nil {
+			return r, err
+		}
+	}
+
+	return r, db.verifyActions(repair, &r)
+}
+
+// check the year-month folders and half-day files of a target
+func (db *TDB) verifyAlias(aliasedHome, target string, repair bool, r *Report) error {
+	folders, err := readFolderNames(aliasedHome)
+	if err != nil {
+		return err
+	}
+	sort.Strings(folders)
+
+	for _, folder := range folders {
+		subFolder := filepath.Join(aliasedHome, folder)
+		exist, err := checkFolderExist(subFolder)
+		if err != nil && err != ErrPathNotFolder {
+			return err
+		}
+		if !exist || !validFolderName(folder) {
+			r.add(Problem{Kind: ProblemBadFolder, Target: target, Path: subFolder})
+			continue
+		}
+
+		names, err := readFolderNames(subFolder)
+		if err != nil {
+			return err
+		}
+		sort.Strings(names)
+
+		var files []fileEncode
+		seen := make(map[fileEncode]bool)
+		for _, n := range names {
+			file, ok := validFileName(folder, n, db.format.exts())
+			if !ok {
+				r.add(Problem{Kind: ProblemBadFile, Target: target, Path: filepath.Join(subFolder, n)})
+				continue
+			}
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+
+		for _, file := range files {
+			r.Files++
+			if err := db.verifyFile(aliasedHome, target, file, repair, r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// check the records and offsets of a half-day file
+func (db *TDB) verifyFile(aliasedHome, target string, file fileEncode, repair bool, r *Report) error {
+	baseName := slotBaseName(aliasedHome, file)
+
+	var unlock func()
+	if repair {
+		unlock = srcLocker.WriteLock(encodeAliasAndFile(aliasedHome, file))
+	} else {
+		unlock = srcLocker.ReadLock(encodeAliasAndFile(aliasedHome, file))
+	}
+	defer unlock()
+
+	records, err := db.format.read(baseName)
+	if os.IsNotExist(err) {
+		// a v1 slt file without idx file
+		records, err = nil, &CorruptionError{Path: baseName, Torn: true}
+	}
+	if cerr, ok := err.(*CorruptionError); ok {
+		if cerr.Torn {
+			pr := Problem{Kind: ProblemTornFile, Target: target, Path: baseName, Detail: cerr.Error()}
+			if repair {
+				if _, err := db.format.repair(baseName); err != nil {
+					return err
+				}
+				pr.Repaired = true
+			}
+			r.add(pr)
+		}
+		if cerr.Bad > 0 {
+			r.add(Problem{
+				Kind:   ProblemChecksum,
+				Target: target,
+				Path:   baseName,
+				Detail: fmt.Sprintf("bad=%d", cerr.Bad),
+			})
+		}
+	} else if err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		if record.offset >= halfDaySeconds {
+			r.add(Problem{
+				Kind:   ProblemBadOffset,
+				Target: target,
+				Path:   baseName,
+				Detail: fmt.Sprintf("record=%d offset=%d", i, record.offset),
+			})
+		}
+	}
+	return nil
+}
+
+// check every action value
+func (db *TDB) verifyActions(repair bool, r *Report) error {
+	db.action.contentLock.Lock()
+	defer db.action.contentLock.Unlock()
+
+	var targets []string
+	for target, v := range db.action.content {
+		if _, _, err := decodeAction(v); err != nil {
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+
+	for _, target := range targets {
+		pr := Problem{Kind: ProblemBadAction, Target: target, Detail: fmt.Sprintf("value=%x", db.action.content[target])}
+		if repair {
+			if err := db.deleteActions([]string{target}); err != nil {
+				return err
+			}
+			pr.Repaired = true
+		}
+		r.add(pr)
+	}
+	return nil
+}
+
+// a year-month folder name like "201703"
+func validFolderName(folder string) bool {
+	if len(folder) != 6 {
+		return false
+	}
+	v, err := strconv.Atoi(folder)
+	if err != nil {
+		return false
+	}
+	month := v % 100
+	return month >= 1 && month <= 12
+}
+
+// a half-day file name like "025.idx" with one of the exts in the folder, encoded the same way back
+func validFileName(folder, name string, exts []string) (fileEncode, bool) {
+	ext := filepath.Ext(name)
+	if !hasExt(exts, ext) {
+		return 0, false
+	}
+
+	file, err := encodeFromPath(folder, name)
+	if err != nil {
+		return 0, false
+	}
+	if day := file.day(); day < 1 || day > 31 {
+		return 0, false
+	}
+	if v := uint32(file) % 10; v != 0 && v != 5 {
+		return 0, false
+	}
+
+	encodedFolder, encodedName := file.path()
+	if encodedFolder != folder || encodedName+ext != name {
+		return 0, false
+	}
+	return file, true
+}
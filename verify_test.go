@@ -0,0 +1,145 @@
+package tdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	folder := "test_verify"
+	defer os.RemoveAll(folder)
+
+	db, err := OpenWithOptions(folder, Options{Version: 1})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := uint32(1491134201)
+	assert.NoError(t, db.AddSlot("a", start, 20), "should have no error")
+	assert.NoError(t, db.AddSlot("b", start, 20), "should have no error")
+	assert.NoError(t, db.AddSlot("c", start, 20), "should have no error")
+
+	report, err := db.Verify(context.Background())
+	assert.NoError(t, err, "verify wrong")
+	assert.True(t, report.OK(), "should have no problem")
+	assert.Equal(t, 3, report.Targets, "targets wrong")
+	assert.Equal(t, 3, report.Files, "files wrong")
+
+	// break things
+	aHome, _ := db.getAliasedHome("a")
+	bHome, _ := db.getAliasedHome("b")
+	cHome, _ := db.getAliasedHome("c")
+	file := encodeFileFromUnix(start, db.loc)
+	subFolder, _ := file.path()
+
+	assert.NoError(t, os.RemoveAll(aHome), "remove wrong")
+	assert.NoError(t, appendToFile(slotBaseName(bHome, file)+offsetExt, []byte{0xff, 0xff}, false), "append wrong")
+	assert.NoError(t, appendToFile(slotBaseName(bHome, file)+slotExt, []byte{1, 0, 0, 0}, false), "append wrong")
+	assert.NoError(t, appendToFile(slotBaseName(cHome, file)+slotExt, []byte{1}, false), "append wrong")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cHome, subFolder, "365.idx"), nil, 0644), "write wrong")
+	assert.NoError(t, createFolder(filepath.Join(cHome, "201713")), "create wrong")
+	assert.NoError(t, createFolder(filepath.Join(folder, slotsFolder, "orphan")), "create wrong")
+	db.action.content["bad"] = []byte("bad")
+
+	report, err = db.Verify(context.Background())
+	assert.NoError(t, err, "verify wrong")
+	assert.False(t, report.OK(), "should have problems")
+
+	kinds := make(map[ProblemKind]int)
+	for _, pr := range report.Problems {
+		kinds[pr.Kind]++
+		assert.False(t, pr.Repaired, "verify should not repair")
+	}
+	assert.Equal(t, map[ProblemKind]int{
+		ProblemMissingAlias: 1,
+		ProblemOrphanAlias:  1,
+		ProblemBadFolder:    1,
+		ProblemBadFile:      1,
+		ProblemTornFile:     1,
+		ProblemBadOffset:    1,
+		ProblemBadAction:    1,
+	}, kinds, "problems wrong")
+
+	report, err = db.Repair(context.Background())
+	assert.NoError(t, err, "repair wrong")
+	for _, pr := range report.Problems {
+		switch pr.Kind {
+		case ProblemMissingAlias, ProblemTornFile, ProblemBadAction:
+			assert.True(t, pr.Repaired, "should be repaired: %s", pr)
+		default:
+			assert.False(t, pr.Repaired, "should not be repaired: %s", pr)
+		}
+	}
+
+	exist, err := checkFolderExist(aHome)
+	assert.NoError(t, err, "should have no error")
+	assert.True(t, exist, "alias folder should be created")
+	assert.Nil(t, db.action.getInfoValue("bad"), "bad action should be dropped")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.Verify(ctx)
+	assert.Equal(t, context.Canceled, err, "should be canceled")
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	folder := "test_verify_checksum"
+	defer os.RemoveAll(folder)
+
+	db, err := Open(folder)
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := uint32(1491134201)
+	assert.NoError(t, db.AddSlot("a", start, 20), "should have no error")
+	assert.NoError(t, db.AddSlot("a", start+30, 20), "should have no error")
+
+	// flip a bit of the first record
+	aHome, _ := db.getAliasedHome("a")
+	recName := slotBaseName(aHome, encodeFileFromUnix(start, db.loc)) + recordExt
+	b, err := ioutil.ReadFile(recName)
+	assert.NoError(t, err, "read wrong")
+	b[0] ^= 1
+	assert.NoError(t, ioutil.WriteFile(recName, append(b, 1, 2, 3), 0644), "write wrong")
+
+	report, err := db.Repair(context.Background())
+	assert.NoError(t, err, "verify wrong")
+	assert.False(t, report.OK(), "checksum can't be repaired")
+	if assert.Len(t, report.Problems, 2, "problems wrong") {
+		assert.Equal(t, ProblemTornFile, report.Problems[0].Kind, "problem wrong")
+		assert.True(t, report.Problems[0].Repaired, "torn record should be repaired")
+		assert.Equal(t, ProblemChecksum, report.Problems[1].Kind, "problem wrong")
+	}
+
+	starts, _, err := db.GetSlots("a", 0, 0)
+	assert.NoError(t, err, "bad records are reported, not returned")
+	assert.Equal(t, [][]uint32{{start + 30}}, starts, "starts wrong")
+}
+
+func TestValidNames(t *testing.T) {
+	assert.True(t, validFolderName("201703"), "folder should be valid")
+	assert.False(t, validFolderName("201700"), "month 0")
+	assert.False(t, validFolderName("2017031"), "length wrong")
+	assert.False(t, validFolderName("abcdef"), "not a number")
+
+	exts := formatV1{}.exts()
+	file, ok := validFileName("201703", "025.idx", exts)
+	assert.True(t, ok, "file should be valid")
+	assert.Equal(t, fileEncode(201703025), file, "file encode wrong")
+	_, ok = validFileName("201703", "025.txt", exts)
+	assert.False(t, ok, "ext wrong")
+	_, ok = validFileName("201703", "025.rec", exts)
+	assert.False(t, ok, "ext of another format")
+	_, ok = validFileName("201703", "003.slt", exts)
+	assert.False(t, ok, "half-day digit wrong")
+	_, ok = validFileName("201703", "320.slt", exts)
+	assert.False(t, ok, "day wrong")
+}
@@ -0,0 +1,50 @@
+package tdb
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// startWorker runs fn every interval in background until the database is closed.
+// Errors are reported through report.
+func (db *TDB) startWorker(name string, interval time.Duration, fn func() error) {
+	db.workers.Add(1)
+	go func() {
+		defer db.workers.Done()
+		for {
+			select {
+			case <-db.closing:
+				return
+			case <-db.clock.After(interval):
+				db.log("worker run", zap.String("name", name))
+				if err := fn(); err != nil {
+					db.report(err)
+				}
+			}
+		}
+	}()
+}
+
+// report an error from background workers to the OnError callback.
+func (db *TDB) report(err error) {
+	if db.opts.OnError != nil {
+		db.opts.OnError(err)
+		return
+	}
+	db.warn("background error", zap.Error(err))
+}
+
+// closingContext returns a context cancelled once the database is closing.
+func (db *TDB) closingContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-db.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
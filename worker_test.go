@@ -0,0 +1,57 @@
+package tdb
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpirationWorker(t *testing.T) {
+	folder := "test_expiration_worker"
+	defer os.RemoveAll(folder)
+
+	clock := newFakeClock(time.Now())
+	errs := make(chan error, 1)
+	db, err := OpenWithOptions(folder, Options{
+		ExpireInterval: time.Second,
+		Clock:          clock,
+		OnError:        func(err error) { errs <- err },
+	})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+
+	target := string(randBytes(6))
+	now := uint32(time.Now().Unix())
+	assert.NoError(t, db.AddAction(target, now-actionExp-2), "error add an action")
+
+	clock.tick()
+	targets, _, _, err := db.GetActions()
+	assert.NoError(t, err, "get all actions wrong")
+	assert.Len(t, targets, 0, "action should be expired by the worker")
+
+	starts, _, err := db.GetSlots(target, 0, 0)
+	assert.NoError(t, err, "get slots wrong")
+	assert.Len(t, starts, 1, "should have a slot")
+
+	// errors are reported through the callback
+	db.action.content[target] = []byte("bad")
+	clock.tick()
+	select {
+	case err := <-errs:
+		assert.Equal(t, ErrActionValue, err, "error wrong")
+	default:
+		t.Error("should report the error")
+	}
+	delete(db.action.content, target)
+
+	// worker stops on close
+	assert.NoError(t, db.Close(), "error closing")
+	select {
+	case <-clock.timers:
+		t.Error("worker should be stopped")
+	default:
+	}
+}
@@ -0,0 +1,277 @@
+package tdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	zoneKey = "zone"
+
+	// marker of an unfinished re-bucketing, holds the from and to zones
+	rebucketMarker = "__rebucket__"
+
+	newSlotsFolder = "slots.new"
+	oldSlotsFolder = "slots.old"
+)
+
+// Zone decides the time zone half-day slot files are bucketed in.
+type Zone uint8
+
+const (
+	// ZoneLocal buckets files in the local zone of the host.
+	// Files move with DST and zone changes, it is kept for older databases.
+	ZoneLocal Zone = iota
+	// ZoneUTC buckets files in UTC.
+	ZoneUTC
+	// ZoneFixed buckets files in the fixed zone of ZoneOffset recorded at creation.
+	ZoneFixed
+)
+
+var zoneNames = []string{"local", "utc", "fixed"}
+
+func (z Zone) String() string {
+	if int(z) < len(zoneNames) {
+		return zoneNames[z]
+	}
+	return fmt.Sprintf("zone(%d)", uint8(z))
+}
+
+// ParseZone parses the name of a zone: local, utc or fixed.
+func ParseZone(name string) (Zone, error) {
+	for i, n := range zoneNames {
+		if strings.EqualFold(n, name) {
+			return Zone(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown zone %q", name)
+}
+
+// Zone used to bucket slot files. Databases created before zones were recorded use ZoneLocal.
+func (db *TDB) Zone() (Zone, error) {
+	return metaZone(db.meta)
+}
+
+// get the zone in the metadata
+func metaZone(meta *info) (Zone, error) {
+	b := meta.getInfoValue(zoneKey)
+	if b == nil {
+		return ZoneLocal, nil
+	}
+	if len(b) != 1 || int(b[0]) >= len(zoneNames) {
+		return 0, fmt.Errorf("wrong zone in metadata: %v", b)
+	}
+	return Zone(b[0]), nil
+}
+
+// get the location to bucket files of the zone
+func zoneLocation(meta *info, zone Zone) (*time.Location, error) {
+	switch zone {
+	case ZoneLocal:
+		return time.Local, nil
+	case ZoneUTC:
+		return time.UTC, nil
+	case ZoneFixed:
+		b := meta.getInfoValue(zoneOffsetKey)
+		if b == nil {
+			return nil, ErrNotExist
+		}
+		offset, err := decodeZoneOffset(b)
+		if err != nil {
+			return nil, err
+		}
+		return time.FixedZone("", int(offset)), nil
+	}
+	return nil, fmt.Errorf("unknown zone %d", zone)
+}
+
+// Rebucket moves every slot of the database folder into the half-day files of the zone.
+// The folder is copied to "<folder>.<zone>.bak" first. If it is interrupted,
+// calling Rebucket again with the same zone resumes it.
+// The database should not be opened while re-bucketing.
+func Rebucket(folder string, zone Zone) error {
+	if int(zone) >= len(zoneNames) {
+		return fmt.Errorf("unknown zone %d", zone)
+	}
+
+	meta, err := createInfo(filepath.Join(folder, metafile))
+	if err != nil {
+		return err
+	}
+	if len(meta.content) == 0 {
+		return ErrNotExist
+	}
+
+	markerPath := filepath.Join(folder, rebucketMarker)
+	var from Zone
+	b, err := ioutil.ReadFile(markerPath)
+	resume := err == nil
+	if resume {
+		if len(b) != 2 {
+			return fmt.Errorf("wrong re-bucketing marker, byte length should be 2, but is %d", len(b))
+		}
+		if Zone(b[1]) != zone {
+			return fmt.Errorf("an unfinished re-bucketing is to zone %s", Zone(b[1]))
+		}
+		from = Zone(b[0])
+	} else if !os.IsNotExist(err) {
+		return err
+	} else {
+		if from, err = metaZone(meta); err != nil {
+			return err
+		}
+		if from == zone {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(folder, migrateMarker)); err == nil {
+			return ErrMigrating
+		}
+	}
+
+	v, err := metaVersion(meta)
+	if err != nil {
+		return err
+	}
+	format, err := formatOf(v)
+	if err != nil {
+		return err
+	}
+	fromLoc, err := zoneLocation(meta, from)
+	if err != nil {
+		return err
+	}
+	toLoc, err := zoneLocation(meta, zone)
+	if err != nil {
+		return err
+	}
+
+	if !resume {
+		backup, err := backupPath(folder, from.String())
+		if err != nil {
+			return err
+		}
+		p("backup before re-bucketing", zap.String("path", folder), zap.String("backup", backup))
+		if err := os.RemoveAll(backup); err != nil {
+			return err
+		}
+		if err := copyFolder(folder, backup); err != nil {
+			return err
+		}
+		if err := writeFile(markerPath, []byte{byte(from), byte(zone)}, true); err != nil {
+			return err
+		}
+	}
+
+	slotsHome := filepath.Join(folder, slotsFolder)
+	newHome := filepath.Join(folder, newSlotsFolder)
+	oldHome := filepath.Join(folder, oldSlotsFolder)
+
+	// the old slots are moved aside only after the new ones are complete
+	oldExist, err := checkFolderExist(oldHome)
+	if err != nil {
+		return err
+	}
+	if !oldExist {
+		if err := createFolder(slotsHome); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(newHome); err != nil {
+			return err
+		}
+		if err := rebucketSlots(format, slotsHome, newHome, fromLoc, toLoc); err != nil {
+			return err
+		}
+		if err := os.Rename(slotsHome, oldHome); err != nil {
+			return err
+		}
+	}
+
+	slotsExist, err := checkFolderExist(slotsHome)
+	if err != nil {
+		return err
+	}
+	if !slotsExist {
+		if err := os.Rename(newHome, slotsHome); err != nil {
+			return err
+		}
+	}
+
+	if err := meta.updateInfo([]string{zoneKey}, [][]byte{{byte(zone)}}); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(oldHome); err != nil {
+		return err
+	}
+	return os.Remove(markerPath)
+}
+
+// write every slot under slotsHome into the half-day files of toLoc under newHome
+func rebucketSlots(format slotFormat, slotsHome, newHome string, fromLoc, toLoc *time.Location) error {
+	if err := createFolder(newHome); err != nil {
+		return err
+	}
+
+	aliases, err := readFolderNames(slotsHome)
+	if err != nil {
+		return err
+	}
+
+	for _, alias := range aliases {
+		aliasedHome := filepath.Join(slotsHome, alias)
+		if _, err := checkFolderExist(aliasedHome); err == ErrPathNotFolder {
+			continue
+		}
+		newAliasedHome := filepath.Join(newHome, alias)
+		if err := createFolder(newAliasedHome); err != nil {
+			return err
+		}
+
+		files := make(map[fileEncode][]slotRecord)
+		err := walkSlotFiles(aliasedHome, format.exts(), func(subFolder, fileName string) error {
+			file, err := encodeFromPath(subFolder, fileName)
+			if err != nil {
+				return err
+			}
+
+			records, err := format.read(filepath.Join(aliasedHome, subFolder, fileName))
+			if cerr, ok := err.(*CorruptionError); ok {
+				p("re-bucket consistent records only", zap.Error(cerr))
+			} else if os.IsNotExist(err) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			origin := file.origin(fromLoc)
+			for _, r := range records {
+				start := origin + uint32(r.offset)
+				newFile := encodeFileFromUnix(start, toLoc)
+				files[newFile] = append(files[newFile], slotRecord{uint16(start - newFile.origin(toLoc)), r.howlong})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for file, records := range files {
+			sort.SliceStable(records, func(i, j int) bool { return records[i].offset < records[j].offset })
+
+			subFolder, _ := file.path()
+			if err := createFolder(filepath.Join(newAliasedHome, subFolder)); err != nil {
+				return err
+			}
+			if err := format.write(slotBaseName(newAliasedHome, file), records); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
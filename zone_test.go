@@ -0,0 +1,116 @@
+package tdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseZone(t *testing.T) {
+	for _, z := range []Zone{ZoneLocal, ZoneUTC, ZoneFixed} {
+		parsed, err := ParseZone(z.String())
+		assert.NoError(t, err, "parse wrong")
+		assert.Equal(t, z, parsed, "zone wrong")
+	}
+
+	parsed, err := ParseZone("UTC")
+	assert.NoError(t, err, "parse wrong")
+	assert.Equal(t, ZoneUTC, parsed, "name should be case insensitive")
+
+	_, err = ParseZone("mars")
+	assert.Error(t, err, "unknown zone")
+	assert.Equal(t, "zone(9)", Zone(9).String(), "string wrong")
+}
+
+func TestZone(t *testing.T) {
+	folder := "test_zone"
+	defer os.RemoveAll(folder)
+
+	db, err := OpenWithOptions(folder, Options{Zone: ZoneUTC})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	zone, err := db.Zone()
+	assert.NoError(t, err, "zone wrong")
+	assert.Equal(t, ZoneUTC, zone, "zone should be recorded")
+	assert.Equal(t, time.UTC, db.loc, "location wrong")
+
+	// 2017-04-02 23:00:00 UTC is in the second half of the day
+	start := uint32(1491174000)
+	assert.NoError(t, db.AddSlot("a", start, 20), "add slot wrong")
+	_, err = os.Stat(filepath.Join(folder, slotsFolder, string(db.slot.getInfoValue("a")), "201704", "025"+recordExt))
+	assert.NoError(t, err, "file should be bucketed in UTC")
+	assert.NoError(t, db.Close(), "close wrong")
+
+	// the zone of an existing database is kept
+	db, err = OpenWithOptions(folder, Options{Zone: ZoneFixed})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	zone, err = db.Zone()
+	assert.NoError(t, err, "zone wrong")
+	assert.Equal(t, ZoneUTC, zone, "zone should be kept")
+	assert.NoError(t, db.Close(), "close wrong")
+}
+
+func TestRebucket(t *testing.T) {
+	folder := "test_rebucket"
+	backup := folder + ".local.bak"
+	defer os.RemoveAll(folder)
+	defer os.RemoveAll(backup)
+
+	local := time.Local
+	time.Local = time.FixedZone("", 5*3600)
+	defer func() { time.Local = local }()
+
+	db, err := OpenWithOptions(folder, Options{})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	// 2017-04-02 10:00:00 and 13:00:00 UTC, both in the local afternoon but split by UTC noon
+	starts := []uint32{1491127200, 1491138000}
+	for _, s := range starts {
+		assert.NoError(t, db.AddSlot("a", s, 20), "add slot wrong")
+	}
+	files, err := db.getTargetFiles("a", 0, 0)
+	assert.NoError(t, err, "get files wrong")
+	assert.Len(t, files, 1, "one local half-day")
+	assert.NoError(t, db.Close(), "close wrong")
+
+	// refuse to open while re-bucketing
+	assert.NoError(t, writeFile(filepath.Join(folder, rebucketMarker), []byte{byte(ZoneLocal), byte(ZoneUTC)}, false), "write marker wrong")
+	_, err = Open(folder)
+	assert.Equal(t, ErrMigrating, err, "should refuse to open")
+	assert.Error(t, Rebucket(folder, ZoneFixed), "unfinished to another zone")
+
+	// resume
+	assert.NoError(t, copyFolder(folder, backup), "backup wrong")
+	assert.NoError(t, Rebucket(folder, ZoneUTC), "rebucket wrong")
+	_, err = os.Stat(filepath.Join(folder, rebucketMarker))
+	assert.True(t, os.IsNotExist(err), "marker should be removed")
+
+	db, err = OpenWithOptions(folder, Options{ReadOnly: true})
+	if !assert.NoError(t, err, "should have no error") {
+		t.Fatal(err)
+	}
+	zone, err := db.Zone()
+	assert.NoError(t, err, "zone wrong")
+	assert.Equal(t, ZoneUTC, zone, "zone should be updated")
+
+	files, err = db.getTargetFiles("a", 0, 0)
+	assert.NoError(t, err, "get files wrong")
+	assert.Len(t, files, 2, "two UTC half-days")
+
+	gotStarts, gotSlots, err := db.GetSlots("a", 0, 0)
+	assert.NoError(t, err, "get slots wrong")
+	assert.Equal(t, [][]uint32{{starts[0]}, {starts[1]}}, gotStarts, "starts should be kept")
+	assert.Equal(t, [][]uint32{{20}, {20}}, gotSlots, "slots should be kept")
+	assert.NoError(t, db.Close(), "close wrong")
+
+	// nothing to do
+	assert.NoError(t, Rebucket(folder, ZoneUTC), "rebucket wrong")
+	assert.Error(t, Rebucket(folder, Zone(9)), "unknown zone")
+}